@@ -0,0 +1,188 @@
+package glog
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	errQueueFull    = errors.New("glog: async queue full")
+	errWriterClosed = errors.New("glog: writer closed")
+)
+
+// asyncWriter copies entries onto a bounded queue drained by a background
+// goroutine, so a slow sink does not delay requests. When the queue is full
+// entries are dropped and counted, or the caller waits when block is set.
+//
+// With a flush interval entries are batched: the goroutine writes them in
+// one call once batchSize bytes are accumulated or the interval elapsed.
+type asyncWriter struct {
+	w     io.Writer
+	queue chan []byte
+	block bool
+	stop  chan struct{}
+	// failures counts the entries lost, see LoggerConfig.writeTo.
+	failures *uint64
+
+	interval  time.Duration
+	batchSize int
+	flushReq  chan struct{}
+	// batch and batched are only used by the background goroutine.
+	batch   []byte
+	batched int
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// pending counts the entries accepted but not written yet.
+	pending int
+	closed  bool
+}
+
+func newAsyncWriter(w io.Writer, failures *uint64, size int, block bool, interval time.Duration, batchSize int) *asyncWriter {
+	a := &asyncWriter{
+		w:         w,
+		failures:  failures,
+		queue:     make(chan []byte, size),
+		block:     block,
+		stop:      make(chan struct{}),
+		interval:  interval,
+		batchSize: batchSize,
+		flushReq:  make(chan struct{}, 1),
+	}
+	a.cond = sync.NewCond(&a.mu)
+	go a.run()
+	return a
+}
+
+func (a *asyncWriter) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return 0, errWriterClosed
+	}
+	a.pending++
+	a.mu.Unlock()
+
+	b := append([]byte(nil), p...)
+	if a.block {
+		a.queue <- b
+		return len(p), nil
+	}
+	select {
+	case a.queue <- b:
+		return len(p), nil
+	default:
+		a.done(1)
+		return 0, errQueueFull
+	}
+}
+
+func (a *asyncWriter) run() {
+	var tick <-chan time.Time
+	if a.interval > 0 {
+		t := time.NewTicker(a.interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case b := <-a.queue:
+			a.add(b)
+		case <-tick:
+			a.flushBatch()
+		case <-a.flushReq:
+			for n := len(a.queue); n > 0; n-- {
+				a.add(<-a.queue)
+			}
+			a.flushBatch()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// add writes b, or appends it to the batch when batching.
+func (a *asyncWriter) add(b []byte) {
+	if a.interval <= 0 {
+		a.write(b, 1)
+		a.done(1)
+		return
+	}
+	a.batch = append(a.batch, b...)
+	a.batched++
+	if len(a.batch) >= a.batchSize {
+		a.flushBatch()
+	}
+}
+
+// flushBatch writes the batched entries.
+func (a *asyncWriter) flushBatch() {
+	if a.batched == 0 {
+		return
+	}
+	a.write(a.batch, a.batched)
+	a.done(a.batched)
+	a.batch, a.batched = a.batch[:0], 0
+}
+
+// write writes n entries held by b, counting failures like
+// LoggerConfig.writeTo.
+func (a *asyncWriter) write(b []byte, n int) {
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddUint64(a.failures, uint64(n))
+		}
+	}()
+	if _, err := a.w.Write(b); err != nil {
+		atomic.AddUint64(a.failures, uint64(n))
+	}
+}
+
+func (a *asyncWriter) done(n int) {
+	a.mu.Lock()
+	a.pending -= n
+	if a.pending == 0 {
+		a.cond.Broadcast()
+	}
+	a.mu.Unlock()
+}
+
+// requestFlush asks the goroutine to write the batch without waiting for
+// the interval.
+func (a *asyncWriter) requestFlush() {
+	select {
+	case a.flushReq <- struct{}{}:
+	default:
+	}
+}
+
+// Flush blocks until every accepted entry has been written.
+func (a *asyncWriter) Flush() {
+	a.requestFlush()
+	a.mu.Lock()
+	for a.pending > 0 {
+		a.cond.Wait()
+	}
+	a.mu.Unlock()
+}
+
+// Close stops accepting entries, drains the queue and stops the background
+// goroutine. The underlying writer is left open.
+func (a *asyncWriter) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.requestFlush()
+	for a.pending > 0 {
+		a.cond.Wait()
+	}
+	a.mu.Unlock()
+	close(a.stop)
+	return nil
+}
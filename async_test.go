@@ -0,0 +1,196 @@
+package glog
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// slowWriter delays every write.
+type slowWriter struct {
+	syncBuffer
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return w.syncBuffer.Write(p)
+}
+
+func TestAsyncCloseLosesNothing(t *testing.T) {
+	for _, interval := range []time.Duration{0, 5 * time.Millisecond} {
+		out := &slowWriter{delay: 50 * time.Microsecond}
+		extra, errOut, aborted, shadow := new(syncBuffer), new(syncBuffer), new(syncBuffer), new(syncBuffer)
+		r, _, m := newTestRouter(t, LoggerConfig{
+			Outputs:       []io.Writer{out, extra},
+			ErrorOutput:   errOut,
+			AbortedOutput: aborted,
+			ShadowFormat:  "${path}\n",
+			ShadowOutput:  shadow,
+			Format:        "${path}\n",
+			Async:         true,
+			QueueSize:     8,
+			BlockOnFull:   true,
+			FlushInterval: interval,
+		})
+		r.GET("/ok/:n", func(ctx *gin.Context) {})
+		r.GET("/fail/:n", func(ctx *gin.Context) { ctx.Status(http.StatusInternalServerError) })
+		r.GET("/abort/:n", func(ctx *gin.Context) { ctx.AbortWithStatus(http.StatusForbidden) })
+
+		const n = 300
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				kind := []string{"ok", "fail", "abort"}[i%3]
+				serve(r, "GET", "/"+kind+"/"+strconv.Itoa(i), nil)
+			}(i)
+		}
+		wg.Wait()
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range []struct {
+			name string
+			w    interface{ Lines() []string }
+			want int
+		}{
+			{"Output", out, 2 * n / 3},
+			{"Outputs[1]", extra, 2 * n / 3},
+			{"ErrorOutput", errOut, n / 3},
+			{"AbortedOutput", aborted, n / 3},
+			{"ShadowOutput", shadow, n},
+		} {
+			if got := len(c.w.Lines()); got != c.want {
+				t.Errorf("interval %v: %s has %d lines, want %d", interval, c.name, got, c.want)
+			}
+		}
+		if s := m.Stats(); s.Dropped != 0 || s.OutputFailures != 0 || s.ShadowFailures != 0 {
+			t.Errorf("interval %v: stats %+v", interval, s)
+		}
+	}
+}
+
+// blockingWriter blocks every write until release is closed.
+type blockingWriter struct {
+	syncBuffer
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return w.syncBuffer.Write(p)
+}
+
+func TestAsyncSideOutputsDoNotBlock(t *testing.T) {
+	block := &blockingWriter{release: make(chan struct{})}
+	r, out, m := newTestRouter(t, LoggerConfig{
+		Format:        "${path}\n",
+		ErrorOutput:   block,
+		AbortedOutput: block,
+		ShadowFormat:  "${path}\n",
+		ShadowOutput:  block,
+		Async:         true,
+	})
+	r.GET("/fail", func(ctx *gin.Context) { ctx.Status(http.StatusInternalServerError) })
+	r.GET("/abort", func(ctx *gin.Context) { ctx.AbortWithStatus(http.StatusForbidden) })
+	done := make(chan struct{})
+	go func() {
+		serve(r, "GET", "/fail", nil)
+		serve(r, "GET", "/abort", nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request blocked on a side output")
+	}
+	close(block.release)
+	m.Close()
+	if got := block.Lines(); len(got) != 4 {
+		t.Errorf("side output lines = %q, want the error and aborted entries and their shadows", got)
+	}
+	if got := out.Lines(); len(got) != 1 || got[0] != "/fail" {
+		t.Errorf("Output lines = %q", got)
+	}
+}
+
+func TestAsyncSideOutputSharesQueue(t *testing.T) {
+	out := new(syncBuffer)
+	r, _, m := newTestRouter(t, LoggerConfig{
+		Output:        out,
+		ErrorOutput:   out,
+		AbortedOutput: out,
+		Format:        "${path}\n",
+		Async:         true,
+	})
+	if len(m.config.sideAsync) != 0 {
+		t.Errorf("%d queues for side outputs that are Output", len(m.config.sideAsync))
+	}
+	r.GET("/:n", func(ctx *gin.Context) {
+		if ctx.Param("n") == "1" {
+			ctx.Status(http.StatusInternalServerError)
+		}
+	})
+	for i := 0; i < 3; i++ {
+		serve(r, "GET", "/"+strconv.Itoa(i), nil)
+	}
+	m.Close()
+	if got := out.Lines(); len(got) != 4 || got[0] != "/0" || got[1] != "/1" || got[2] != "/1" || got[3] != "/2" {
+		t.Errorf("lines = %q, want the error entry twice, in order", got)
+	}
+}
+
+func TestAsyncSlowWriter(t *testing.T) {
+	out := &slowWriter{delay: 100 * time.Millisecond}
+	r, _, m := newTestRouter(t, LoggerConfig{Output: out, Format: "${path}\n", Async: true})
+	r.GET("/:n", func(ctx *gin.Context) {})
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		serve(r, "GET", "/"+strconv.Itoa(i), nil)
+		if d := time.Since(start); d > 50*time.Millisecond {
+			t.Errorf("request %d took %v with a writer taking 100ms", i, d)
+		}
+	}
+	if got := len(out.Lines()); got == 5 {
+		t.Error("entries written before the requests returned")
+	}
+	m.Close()
+	if got := out.Lines(); len(got) != 5 || got[0] != "/0" || got[4] != "/4" {
+		t.Errorf("lines after Close = %q, want the 5 entries in order", got)
+	}
+}
+
+func TestFlushInterval(t *testing.T) {
+	out := new(syncBuffer)
+	r, _, m := newTestRouter(t, LoggerConfig{Output: out, Format: "${path}\n", FlushInterval: 50 * time.Millisecond})
+	r.GET("/:n", func(ctx *gin.Context) {})
+	serve(r, "GET", "/1", nil)
+	if out.String() != "" {
+		t.Error("entry written before the interval elapsed with an empty batch")
+	}
+	deadline := time.Now().Add(time.Second)
+	for out.String() == "" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := out.Lines(); len(got) != 1 {
+		t.Fatalf("lines after the interval = %q, want /1", got)
+	}
+
+	m.Close()
+
+	// Close writes the batch without waiting for the interval.
+	r, out, m = newTestRouter(t, LoggerConfig{Format: "${path}\n", FlushInterval: time.Hour})
+	r.GET("/:n", func(ctx *gin.Context) {})
+	serve(r, "GET", "/2", nil)
+	m.Close()
+	if got := out.Lines(); len(got) != 1 || got[0] != "/2" {
+		t.Errorf("lines after Close = %q, want /2", got)
+	}
+}
@@ -0,0 +1,223 @@
+package glog
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// requestBody captures the request body for the body tag. Depending on the
+// declared Content-Length it is either buffered up front, skipped entirely
+// or captured while the handler reads it.
+type requestBody struct {
+	io.ReadCloser
+	buf limitedBuffer
+	// placeholder is logged instead of a body that was not captured.
+	placeholder string
+	// complete is set when buf holds the whole body before the handlers
+	// run, see ContextRequestBody.
+	complete bool
+}
+
+// limitedBuffer keeps at most limit bytes of what is written to it, a limit
+// <= 0 keeps everything.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+	// size is the number of bytes written, including the discarded ones.
+	size int64
+}
+
+// multipartPlaceholder is logged instead of multipart bodies.
+const multipartPlaceholder = "[multipart omitted]"
+
+// captureBody prepares the body of r for logging and replaces r.Body so
+// handlers can still read the full content. A limit <= 0 buffers the whole
+// body. Multipart bodies are only captured when multipart is true, other
+// bodies only when their content type matches types, see omittedType.
+func captureBody(r *http.Request, limit int, multipart bool, types []string) *requestBody {
+	b := &requestBody{buf: limitedBuffer{limit: limit}}
+	if r.Body == nil || r.Body == http.NoBody {
+		return b
+	}
+	if p := omittedType(types, r.Header.Get("Content-Type")); p != "" {
+		b.placeholder = p
+		return b
+	}
+	if !multipart && isMultipart(r) {
+		b.placeholder = multipartPlaceholder
+		return b
+	}
+	switch {
+	case limit <= 0:
+		data, _ := ioutil.ReadAll(r.Body)
+		b.buf.write(data)
+		b.complete = true
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	case r.ContentLength > int64(limit):
+		// Too large to keep, do not even start buffering.
+		b.placeholder = "[omitted " + strconv.FormatInt(r.ContentLength, 10) + " bytes]"
+	case r.ContentLength >= 0:
+		// Read at most limit+1 bytes so a body larger than declared is
+		// noticed, then count the rest while the handler reads it.
+		data, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+		b.buf.write(data)
+		b.complete = err == nil && len(data) <= limit
+		b.ReadCloser = r.Body
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), b), b}
+	default:
+		// Unknown length (chunked), capture while the handler reads.
+		b.ReadCloser = r.Body
+		r.Body = b
+	}
+	return b
+}
+
+// omittedType returns the placeholder logged instead of a body of
+// contentType, or "" when types is empty or contentType matches one of
+// them. A pattern ending with '*' matches by prefix, e.g. "text/*". A
+// missing content type is treated as application/octet-stream.
+func omittedType(types []string, contentType string) string {
+	if len(types) == 0 {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+	for _, t := range types {
+		t = strings.ToLower(t)
+		if t == mediaType || strings.HasSuffix(t, "*") && strings.HasPrefix(mediaType, t[:len(t)-1]) {
+			return ""
+		}
+	}
+	return "[omitted: " + mediaType + "]"
+}
+
+func isMultipart(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && strings.HasPrefix(mediaType, "multipart/")
+}
+
+// Read tees the bytes read by the handler into the bounded buffer.
+func (b *requestBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.write(p[:n])
+	return n, err
+}
+
+// String returns the captured body, or a placeholder when the body was not
+// captured.
+func (b *requestBody) String() string {
+	if b.placeholder != "" {
+		return b.placeholder
+	}
+	return b.buf.String()
+}
+
+// limitedWriter adapts a limitedBuffer to io.Writer.
+type limitedWriter struct {
+	b *limitedBuffer
+}
+
+func (w limitedWriter) Write(p []byte) (int, error) {
+	w.b.write(p)
+	return len(p), nil
+}
+
+func (b *limitedBuffer) write(p []byte) {
+	b.size += int64(len(p))
+	if b.limit <= 0 {
+		b.Buffer.Write(p)
+		return
+	}
+	if rest := b.limit - b.Len(); rest > 0 {
+		if len(p) > rest {
+			p = p[:rest]
+		}
+		b.Buffer.Write(p)
+	}
+}
+
+// String returns the kept bytes, followed by a marker with the total size
+// when some were discarded.
+func (b *limitedBuffer) String() string {
+	if b.size > int64(b.Len()) {
+		return b.Buffer.String() + "...(truncated, " + strconv.FormatInt(b.size, 10) + " bytes total)"
+	}
+	return b.Buffer.String()
+}
+
+// maxDecodedBody bounds the decoded size of a compressed body when no
+// limit is set, so a small compression bomb cannot inflate in memory.
+const maxDecodedBody = 4 << 20
+
+// decodeBody decompresses a body captured with the gzip or deflate
+// Content-Encoding, keeping at most limit decoded bytes (limit <= 0 keeps
+// up to maxDecodedBody). Decoding stops there, a longer body ends with
+// "...(truncated, <N> bytes gzip)" where N is the compressed size. It
+// reports false for other encodings. A body that cannot be decoded at all
+// is logged as "<gzip N bytes>".
+func decodeBody(encoding string, b *limitedBuffer, limit int) (string, bool) {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	var (
+		r   io.Reader
+		err error
+	)
+	data := b.Bytes()
+	switch encoding {
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	case "deflate":
+		// Usually zlib wrapped as specified, sometimes raw deflate.
+		if r, err = zlib.NewReader(bytes.NewReader(data)); err != nil {
+			r, err = flate.NewReader(bytes.NewReader(data)), nil
+		}
+	default:
+		return "", false
+	}
+	placeholder := fmt.Sprintf("<%s %d bytes>", encoding, b.size)
+	if err != nil {
+		return placeholder, true
+	}
+	if limit <= 0 {
+		limit = maxDecodedBody
+	}
+	var out bytes.Buffer
+	// The capture may be truncated, keep what decodes. One byte past the
+	// limit tells a longer body apart.
+	_, err = io.Copy(&out, io.LimitReader(r, int64(limit)+1))
+	if out.Len() == 0 && err != nil {
+		return placeholder, true
+	}
+	if out.Len() > limit || b.size > int64(b.Len()) {
+		// Decoding stopped at the limit, or the capture is truncated.
+		if out.Len() > limit {
+			out.Truncate(limit)
+		}
+		return out.String() + "...(truncated, " + strconv.FormatInt(b.size, 10) + " bytes " + encoding + ")", true
+	}
+	return out.String(), true
+}
+
+// compact returns valid JSON without insignificant whitespace, and any
+// other text with runs of whitespace collapsed to a single space.
+func compact(s string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(s)); err == nil {
+		return buf.String()
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,236 @@
+package glog
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func compress(t *testing.T, encoding, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	io.WriteString(w, s)
+	w.Close()
+	return buf.Bytes()
+}
+
+func captured(data []byte, limit int) *limitedBuffer {
+	b := &limitedBuffer{limit: limit}
+	b.write(data)
+	return b
+}
+
+func TestDecodeBody(t *testing.T) {
+	for _, c := range []struct {
+		encoding, header string
+	}{{"gzip", "gzip"}, {"gzip", "X-Gzip"}, {"deflate", "deflate"}, {"raw", "deflate"}} {
+		got, ok := decodeBody(c.header, captured(compress(t, c.encoding, `{"a":1}`), 0), 0)
+		if !ok || got != `{"a":1}` {
+			t.Errorf("%s as %s: %q, %v", c.encoding, c.header, got, ok)
+		}
+	}
+	if _, ok := decodeBody("br", captured([]byte("x"), 0), 0); ok {
+		t.Error("br decoded")
+	}
+	if got, _ := decodeBody("gzip", captured([]byte("not gzip"), 0), 0); got != "<gzip 8 bytes>" {
+		t.Errorf("undecodable body = %q", got)
+	}
+}
+
+func TestDecodeBodyLimit(t *testing.T) {
+	data := compress(t, "gzip", strings.Repeat("a", 100))
+	got, _ := decodeBody("gzip", captured(data, 0), 10)
+	want := strings.Repeat("a", 10) + "...(truncated, " + strconv.Itoa(len(data)) + " bytes gzip)"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, _ := decodeBody("gzip", captured(data, 0), 100); got != strings.Repeat("a", 100) {
+		t.Errorf("body of exactly the limit = %q", got)
+	}
+	// A truncated capture keeps what decodes.
+	var text strings.Builder
+	for i := 0; i < 2000; i++ {
+		text.WriteString(strconv.Itoa(i * i))
+	}
+	long := compress(t, "gzip", text.String())
+	got, _ = decodeBody("gzip", captured(long, len(long)/2), 0)
+	if !strings.HasPrefix(got, "0149162536") || !strings.HasSuffix(got, "...(truncated, "+strconv.Itoa(len(long))+" bytes gzip)") {
+		t.Errorf("truncated capture = %.40q...", got)
+	}
+}
+
+func TestDecodeBodyBomb(t *testing.T) {
+	// 64MiB of zeros compress to about 64KiB.
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	zeros := make([]byte, 1<<20)
+	for i := 0; i < 64; i++ {
+		w.Write(zeros)
+	}
+	w.Close()
+	got, _ := decodeBody("gzip", captured(buf.Bytes(), 0), 0)
+	if len(got) > maxDecodedBody+100 {
+		t.Errorf("decoded %d bytes without a limit", len(got))
+	}
+	if !strings.Contains(got, "...(truncated, ") {
+		t.Errorf("no truncation marker")
+	}
+}
+
+func TestResponseGzip(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${response}\n", MaxResponseLogSize: 200})
+	body := `{"n":"` + strings.Repeat("a", 1000) + `"}`
+	data := compress(t, "gzip", body)
+	r.GET("/", func(ctx *gin.Context) {
+		ctx.Header("Content-Encoding", "gzip")
+		ctx.Data(http.StatusOK, "application/json", data)
+	})
+	serve(r, "GET", "/", nil)
+	want := body[:200] + "...(truncated, " + strconv.Itoa(len(data)) + " bytes gzip)"
+	if got := out.Lines(); len(got) != 1 || got[0] != want {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+}
+
+func TestCaptureContentLength(t *testing.T) {
+	long := strings.Repeat("0123456789", 4)
+	for _, tc := range []struct {
+		name          string
+		body          string
+		contentLength int64
+		want          string
+	}{
+		{"honest", "hello", 5, "hello"},
+		{"honest too large", long, 40, "[omitted 40 bytes]"},
+		{"lying", long, 5, "0123456789012345...(truncated, 40 bytes total)"},
+		{"absent", long, -1, "0123456789012345...(truncated, 40 bytes total)"},
+		{"absent small", "hello", -1, "hello"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, out, _ := newTestRouter(t, LoggerConfig{Format: "${body}\n", MaxBodySize: 16})
+			var seen string
+			r.POST("/", func(ctx *gin.Context) {
+				b, _ := ioutil.ReadAll(ctx.Request.Body)
+				seen = string(b)
+			})
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+			req.ContentLength = tc.contentLength
+			r.ServeHTTP(httptest.NewRecorder(), req)
+			if seen != tc.body {
+				t.Errorf("handler read %q, want %q", seen, tc.body)
+			}
+			if got := strings.TrimSuffix(out.String(), "\n"); got != tc.want {
+				t.Errorf("body = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompactBody(t *testing.T) {
+	pretty := "{\n  \"name\": \"a  b\",\n  \"tags\": [\n    \"x\",\n    \"y\"\n  ]\n}\n"
+	text := "first  line\n    second\tline\r\n\n third"
+	for _, tc := range []struct {
+		compact    bool
+		body, want string
+	}{
+		{true, pretty, `{"name":"a  b","tags":["x","y"]}`},
+		{false, pretty, `{"name":"a  b","tags":["x","y"]}`},
+		{true, text, "first line second line third"},
+		{false, text, "first  linesecond\tline\rthird"},
+	} {
+		r, out, _ := newTestRouter(t, LoggerConfig{Format: "${body}\n", CompactBody: tc.compact})
+		r.POST("/", func(ctx *gin.Context) {})
+		serve(r, "POST", "/", strings.NewReader(tc.body))
+		if got := strings.TrimSuffix(out.String(), "\n"); got != tc.want {
+			t.Errorf("CompactBody %v: body = %q, want %q", tc.compact, got, tc.want)
+		}
+	}
+}
+
+func TestOmittedType(t *testing.T) {
+	types := []string{"application/json", "Text/*"}
+	for _, tc := range []struct {
+		contentType, want string
+	}{
+		{"application/json", ""},
+		{"application/json; charset=utf-8", ""},
+		{"APPLICATION/JSON", ""},
+		{"text/plain", ""},
+		{"text/html; charset=utf-8", ""},
+		{"application/jsonx", "[omitted: application/jsonx]"},
+		{"application/xml", "[omitted: application/xml]"},
+		{"image/png", "[omitted: image/png]"},
+		{"textual/plain", "[omitted: textual/plain]"},
+		{"", "[omitted: application/octet-stream]"},
+		{"not a type", "[omitted: application/octet-stream]"},
+	} {
+		if got := omittedType(types, tc.contentType); got != tc.want {
+			t.Errorf("omittedType(%q) = %q, want %q", tc.contentType, got, tc.want)
+		}
+	}
+	if got := omittedType(nil, "image/png"); got != "" {
+		t.Errorf("omittedType without BodyContentTypes = %q, want every type logged", got)
+	}
+}
+
+func TestBodyContentTypes(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${body}|${response}\n", BodyContentTypes: []string{"application/json", "text/*"}})
+	r.POST("/", func(ctx *gin.Context) {
+		b, _ := ioutil.ReadAll(ctx.Request.Body)
+		ctx.Data(http.StatusOK, ctx.Query("type"), b)
+	})
+	serve(r, "POST", "/?type=text/plain", strings.NewReader(`{"a":1}`), "Content-Type", "application/json")
+	serve(r, "POST", "/?type=image/png", strings.NewReader("PNG"), "Content-Type", "image/png")
+	want := []string{`{"a":1}|{"a":1}`, "[omitted: image/png]|[omitted: image/png]"}
+	if got := out.Lines(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+}
+
+func TestResponseHead(t *testing.T) {
+	for _, format := range []string{"${response_head}\n", "${response_head}|${response}\n"} {
+		r, out, _ := newTestRouter(t, LoggerConfig{Format: format, ResponseHeadSize: 8})
+		r.GET("/", func(ctx *gin.Context) {
+			ctx.String(http.StatusOK, ctx.Query("body"))
+		})
+		for _, body := range []string{"", "short", "12345678", "0123456789abcdef"} {
+			serve(r, "GET", "/?body="+body, nil)
+		}
+		want := []string{"", "short", "12345678", "01234567"}
+		if strings.Contains(format, "|") {
+			want = []string{"|", "short|short", "12345678|12345678", "01234567|0123456789abcdef"}
+		}
+		if got := out.Lines(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: entries = %q, want %q", format, got, want)
+		}
+	}
+
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${response_head}\n"})
+	r.GET("/", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, strings.Repeat("x", 1000))
+	})
+	serve(r, "GET", "/", nil)
+	if got := strings.TrimSuffix(out.String(), "\n"); got != strings.Repeat("x", DefaultLoggerConfig.ResponseHeadSize) {
+		t.Errorf("response_head of %d bytes, want the default %d", len(got), DefaultLoggerConfig.ResponseHeadSize)
+	}
+}
@@ -0,0 +1,203 @@
+package glog
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientBudget is a bounded LRU of token buckets, one per client key. A
+// bucket holds up to limit tokens and refills limit tokens per interval.
+// Entries without a token are suppressed and reported once per interval.
+type clientBudget struct {
+	mu       sync.Mutex
+	limit    float64
+	interval time.Duration
+	size     int
+	now      func() time.Time
+	// lru holds *bucket values, most recently used first.
+	lru     *list.List
+	buckets map[string]*list.Element
+	// start is the beginning of the current reporting interval.
+	start time.Time
+	// stop ends the goroutine writing the summaries, which closes done.
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type bucket struct {
+	key        string
+	tokens     float64
+	last       time.Time
+	suppressed int
+}
+
+// budgetLine reports the entries suppressed for one client.
+type budgetLine struct {
+	Message    string `json:"msg"`
+	Client     string `json:"client"`
+	Suppressed int    `json:"suppressed"`
+}
+
+func newClientBudget(limit int, interval time.Duration, size int, now func() time.Time) *clientBudget {
+	return &clientBudget{
+		limit:    float64(limit),
+		interval: interval,
+		size:     size,
+		now:      now,
+		lru:      list.New(),
+		buckets:  make(map[string]*list.Element),
+		start:    now(),
+	}
+}
+
+// allow takes a token for key. It also returns the summaries due: those of
+// the previous interval once it is over, and the one of an evicted bucket.
+func (b *clientBudget) allow(key string) (bool, []budgetLine) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	lines := b.rollover(nil, false)
+	e, ok := b.buckets[key]
+	if ok {
+		b.lru.MoveToFront(e)
+	} else {
+		e = b.lru.PushFront(&bucket{key: key, tokens: b.limit, last: now})
+		b.buckets[key] = e
+		if b.lru.Len() > b.size {
+			old := b.lru.Back()
+			b.lru.Remove(old)
+			evicted := old.Value.(*bucket)
+			delete(b.buckets, evicted.key)
+			lines = b.report(lines, evicted)
+		}
+	}
+	c := e.Value.(*bucket)
+	c.tokens += now.Sub(c.last).Seconds() * b.limit / b.interval.Seconds()
+	if c.tokens > b.limit {
+		c.tokens = b.limit
+	}
+	c.last = now
+	if c.tokens < 1 {
+		c.suppressed++
+		return false, lines
+	}
+	c.tokens--
+	return true, lines
+}
+
+// summaries returns the summaries of the interval once it is over, or
+// right away with force.
+func (b *clientBudget) summaries(force bool) []budgetLine {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rollover(nil, force)
+}
+
+// rollover appends the summaries of the interval to lines and starts a
+// new one when it is over or with force. It must be called with mu held.
+func (b *clientBudget) rollover(lines []budgetLine, force bool) []budgetLine {
+	now := b.now()
+	if !force && now.Sub(b.start) < b.interval {
+		return lines
+	}
+	for e := b.lru.Front(); e != nil; e = e.Next() {
+		lines = b.report(lines, e.Value.(*bucket))
+	}
+	b.start = now
+	return lines
+}
+
+// report appends the summary of c to lines if it suppressed entries.
+func (b *clientBudget) report(lines []budgetLine, c *bucket) []budgetLine {
+	if c.suppressed == 0 {
+		return lines
+	}
+	lines = append(lines, budgetLine{
+		Message:    fmt.Sprintf("client %s suppressed %d entries", c.key, c.suppressed),
+		Client:     c.key,
+		Suppressed: c.suppressed,
+	})
+	c.suppressed = 0
+	return lines
+}
+
+// validBudgetKey reports whether key may be used as ClientBudgetKey.
+func validBudgetKey(key string) bool {
+	switch {
+	case key == "remote_ip":
+		return true
+	case strings.HasPrefix(key, "header:"):
+		return validTagArg(key[7:])
+	case strings.HasPrefix(key, "context:"):
+		return validTagArg(key[8:])
+	}
+	return false
+}
+
+// withinBudget reports whether the client of ctx may log another entry and
+// writes the summaries that are due.
+func (config *LoggerConfig) withinBudget(ctx *gin.Context) bool {
+	if config.budget == nil {
+		return true
+	}
+	var key string
+	switch k := config.ClientBudgetKey; {
+	case k == "remote_ip":
+		key = ctx.ClientIP()
+	case strings.HasPrefix(k, "header:"):
+		key = ctx.Request.Header.Get(k[7:])
+	case strings.HasPrefix(k, "context:"):
+		key = contextValue(ctx, k[8:])
+	}
+	if key == "" {
+		// Clients without the header or context value would otherwise
+		// share one budget.
+		key = "remote_ip:" + ctx.ClientIP()
+	}
+	ok, lines := config.budget.allow(key)
+	config.writeBudget(lines)
+	return ok
+}
+
+func (config *LoggerConfig) writeBudget(lines []budgetLine) {
+	for _, line := range lines {
+		config.writeSide(LevelWarn, line)
+	}
+}
+
+// startBudget starts the goroutine writing the summaries at the end of
+// each interval, so they do not wait for the next entry.
+func (config *LoggerConfig) startBudget() {
+	b := config.budget
+	b.stop, b.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(b.done)
+		t := time.NewTicker(b.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				config.writeBudget(b.summaries(true))
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopBudget stops the goroutine of startBudget and writes the pending
+// summaries.
+func (config *LoggerConfig) stopBudget() {
+	b := config.budget
+	b.stopOnce.Do(func() {
+		close(b.stop)
+		<-b.done
+		config.writeBudget(b.summaries(true))
+	})
+}
@@ -0,0 +1,167 @@
+package glog
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeClock is a time source advanced by the tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestClientBudgetSummary(t *testing.T) {
+	clock := newFakeClock()
+	b := newClientBudget(2, time.Minute, 10, clock.Now)
+	var allowed int
+	for i := 0; i < 5; i++ {
+		ok, lines := b.allow("1.2.3.4")
+		if ok {
+			allowed++
+		}
+		if len(lines) != 0 {
+			t.Fatalf("summary %+v before the interval ended", lines)
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("allowed %d entries, want 2", allowed)
+	}
+	if ok, _ := b.allow("5.6.7.8"); !ok {
+		t.Error("another client shares the budget")
+	}
+	if lines := b.summaries(false); len(lines) != 0 {
+		t.Errorf("summaries %+v before the interval ended", lines)
+	}
+	clock.Advance(time.Minute)
+	lines := b.summaries(false)
+	if len(lines) != 1 || lines[0].Client != "1.2.3.4" || lines[0].Suppressed != 3 ||
+		lines[0].Message != "client 1.2.3.4 suppressed 3 entries" {
+		t.Fatalf("summaries = %+v", lines)
+	}
+	if lines := b.summaries(true); len(lines) != 0 {
+		t.Errorf("summary %+v reported twice", lines)
+	}
+	if ok, _ := b.allow("1.2.3.4"); !ok {
+		t.Error("budget not refilled after the interval")
+	}
+}
+
+func TestClientBudgetEviction(t *testing.T) {
+	clock := newFakeClock()
+	b := newClientBudget(1, time.Minute, 2, clock.Now)
+	b.allow("a")
+	b.allow("a")
+	b.allow("b")
+	if _, lines := b.allow("a"); len(lines) != 0 {
+		t.Fatalf("summary %+v without eviction", lines)
+	}
+	// b is the least recently used client.
+	_, lines := b.allow("c")
+	if len(lines) != 0 {
+		t.Fatalf("summary %+v for b which suppressed nothing", lines)
+	}
+	_, lines = b.allow("b")
+	if len(lines) != 1 || lines[0].Client != "a" || lines[0].Suppressed != 2 {
+		t.Fatalf("summaries on eviction = %+v, want a with 2", lines)
+	}
+	if len(b.buckets) != 2 || b.lru.Len() != 2 {
+		t.Errorf("%d buckets, %d in the LRU, want 2", len(b.buckets), b.lru.Len())
+	}
+	if ok, _ := b.allow("a"); !ok {
+		t.Error("evicted client kept its empty bucket")
+	}
+}
+
+func TestClientBudgetConcurrent(t *testing.T) {
+	b := newClientBudget(100, time.Hour, 8, time.Now)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed, suppressed := 0, 0
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				ok, lines := b.allow(strconv.Itoa(i % 4))
+				mu.Lock()
+				if ok {
+					allowed++
+				}
+				for _, l := range lines {
+					suppressed += l.Suppressed
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, l := range b.summaries(true) {
+		suppressed += l.Suppressed
+	}
+	if allowed+suppressed != 16*200 {
+		t.Errorf("allowed %d + suppressed %d != %d", allowed, suppressed, 16*200)
+	}
+	if allowed < 400 || allowed > 410 {
+		t.Errorf("allowed %d entries for 4 clients with a budget of 100", allowed)
+	}
+}
+
+func TestClientBudgetMiddleware(t *testing.T) {
+	r, out, m := newTestRouter(t, LoggerConfig{
+		Format:               "${path}\n",
+		ClientBudget:         1,
+		ClientBudgetInterval: 50 * time.Millisecond,
+		ClientBudgetKey:      "header:X-API-Key",
+	})
+	r.GET("/:n", func(ctx *gin.Context) {})
+	for i := 0; i < 3; i++ {
+		serve(r, "GET", "/k"+strconv.Itoa(i), nil, "X-API-Key", "k")
+	}
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/ip"+strconv.Itoa(i), nil)
+		req.RemoteAddr = "10.0.0." + strconv.Itoa(i+1) + ":1234"
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if got := out.Lines(); len(got) != 3 || got[0] != "/k0" || got[1] != "/ip0" || got[2] != "/ip1" {
+		t.Fatalf("entries = %q, want one per key and per remote IP without the header", got)
+	}
+	// The summary is written when the interval ends, without waiting for
+	// another entry.
+	deadline := time.Now().Add(2 * time.Second)
+	for len(out.Lines()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := out.Lines(); len(got) != 4 || parsePairs(got[3])["msg"] != "client k suppressed 2 entries" {
+		t.Fatalf("lines = %q, want the summary of k", got)
+	}
+
+	serve(r, "GET", "/k3", nil, "X-API-Key", "k")
+	serve(r, "GET", "/k4", nil, "X-API-Key", "k")
+	m.Close()
+	got := out.Lines()
+	if last := parsePairs(got[len(got)-1]); last["client"] != "k" || last["suppressed"] != "1" {
+		t.Errorf("lines = %q, want the pending summary written by Close", got)
+	}
+}
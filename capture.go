@@ -0,0 +1,72 @@
+package glog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CaptureOptions overrides the body capture settings for one route, see
+// LoggerConfig.RouteCaptureOverrides.
+type CaptureOptions struct {
+	// MaxBodySize replaces MaxBodyLogSize and MaxResponseLogSize for the
+	// route. 0 keeps them, a negative value removes the limit.
+	MaxBodySize int `yaml:"max_body_size"`
+
+	// DisableRedaction logs the body, response and response_head tags
+	// exactly as received, without redaction or whitespace removal.
+	DisableRedaction bool `yaml:"disable_redaction"`
+
+	// DisableCapture never buffers the bodies of the route, the body,
+	// response and response_head tags render empty.
+	DisableCapture bool `yaml:"disable_capture"`
+}
+
+// CaptureBody returns a route handler enabling the body tag for the route
+// it is registered on. It only has an effect when
+// LoggerConfig.CapturePerRoute is set.
+//
+//	r.POST("/payments", glog.CaptureBody(), handler)
+func CaptureBody() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(ContextCaptureBody, true)
+	}
+}
+
+// CaptureResponse returns a route handler enabling the response tag for the
+// route it is registered on. It only has an effect when
+// LoggerConfig.CapturePerRoute is set.
+func CaptureResponse() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(ContextCaptureResponse, true)
+	}
+}
+
+// captureEnabled reports whether the tag guarded by key is rendered for
+// the request.
+func (config *LoggerConfig) captureEnabled(ctx *gin.Context, key string) bool {
+	return !config.CapturePerRoute || ctx.GetBool(key)
+}
+
+// validateCaptureOverrides checks the keys of RouteCaptureOverrides are
+// route templates and the options do not contradict each other.
+func validateCaptureOverrides(overrides map[string]CaptureOptions) error {
+	for route, o := range overrides {
+		if !strings.HasPrefix(route, "/") {
+			return fmt.Errorf("glog: capture override %q: not a route template", route)
+		}
+		if o.DisableCapture && (o.MaxBodySize != 0 || o.DisableRedaction) {
+			return fmt.Errorf("glog: capture override %q: DisableCapture with other options", route)
+		}
+	}
+	return nil
+}
+
+// limit returns the capture limit for the route given the global one.
+func (o CaptureOptions) limit(global int) int {
+	if o.MaxBodySize != 0 {
+		return o.MaxBodySize
+	}
+	return global
+}
@@ -0,0 +1,60 @@
+package glog
+
+import (
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRouteCaptureOverrides(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format:         "${body}|${response}\n",
+		MaxBodyLogSize: 64,
+		RouteCaptureOverrides: map[string]CaptureOptions{
+			"/raw/:id": {MaxBodySize: -1, DisableRedaction: true},
+			"/off":     {DisableCapture: true},
+			"/small":   {MaxBodySize: 8},
+		},
+	})
+	echo := func(ctx *gin.Context) {
+		b, _ := ioutil.ReadAll(ctx.Request.Body)
+		ctx.Data(http.StatusOK, "application/json", b)
+	}
+	for _, route := range []string{"/default", "/raw/:id", "/off", "/small"} {
+		r.POST(route, echo)
+	}
+	body := `{"password":"secret", "name":"` + strings.Repeat("x", 64) + `"}`
+	for _, target := range []string{"/default", "/raw/1", "/off", "/small"} {
+		serve(r, "POST", target, strings.NewReader(body))
+	}
+	xs := strings.Repeat("x", 64)
+	want := []string{
+		// The body is above MaxBodyLogSize, the response is unlimited.
+		`[omitted 96 bytes]|{"password":"***","name":"` + xs + `"}`,
+		body + "|" + body,
+		"|",
+		`[omitted 96 bytes]|{"passwo...(truncated, 96 bytes total)`,
+	}
+	if got := out.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestValidateCaptureOverrides(t *testing.T) {
+	for _, overrides := range []map[string]CaptureOptions{
+		{"raw": {DisableRedaction: true}},
+		{"/off": {DisableCapture: true, MaxBodySize: 10}},
+		{"/off": {DisableCapture: true, DisableRedaction: true}},
+	} {
+		if _, err := newMiddleware(LoggerConfig{RouteCaptureOverrides: overrides, Output: discard{}}); err == nil {
+			t.Errorf("overrides %v accepted", overrides)
+		}
+	}
+	if _, err := newMiddleware(LoggerConfig{RouteCaptureOverrides: map[string]CaptureOptions{"/off": {DisableCapture: true}}, Output: discard{}}); err != nil {
+		t.Errorf("valid override rejected: %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package glog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// hashChain links every written entry to the SHA-256 of the previous one so
+// that removed or modified lines can be detected.
+type hashChain struct {
+	mu   sync.Mutex
+	prev [sha256.Size]byte
+}
+
+// prevHash returns the hex encoded hash of the previous entry. It must be
+// called with mu held.
+func (c *hashChain) prevHash() string {
+	return hex.EncodeToString(c.prev[:])
+}
+
+// link advances the chain with the entry just written. It must be called
+// with mu held.
+func (c *hashChain) link(entry []byte) {
+	h := sha256.New()
+	h.Write(c.prev[:])
+	h.Write(entry)
+	h.Sum(c.prev[:0])
+}
@@ -0,0 +1,67 @@
+package glog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// verifyChain returns the index of the first line whose prev_hash does not
+// match the lines before it, -1 when the chain is intact.
+func verifyChain(t *testing.T, lines []string) int {
+	t.Helper()
+	var prev [sha256.Size]byte
+	for i, line := range lines {
+		var entry struct {
+			PrevHash string `json:"prev_hash"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		if entry.PrevHash != hex.EncodeToString(prev[:]) {
+			return i
+		}
+		prev = sha256.Sum256(append(prev[:], line+"\n"...))
+	}
+	return -1
+}
+
+func TestTamperEvident(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format:        `{"prev_hash":"${prev_hash}","path":"${path}"}` + "\n",
+		TamperEvident: true,
+	})
+	r.GET("/:n", func(ctx *gin.Context) {})
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			serve(r, "GET", "/"+strconv.Itoa(i), nil)
+		}(i)
+	}
+	wg.Wait()
+	lines := out.Lines()
+	if len(lines) != 50 {
+		t.Fatalf("got %d lines, want 50", len(lines))
+	}
+	if i := verifyChain(t, lines); i >= 0 {
+		t.Fatalf("chain broken at line %d", i)
+	}
+
+	modified := append([]string(nil), lines...)
+	modified[10] = strings.Replace(modified[10], `"path":"/`, `"path":"/x`, 1)
+	if i := verifyChain(t, modified); i != 11 {
+		t.Errorf("modified line detected at %d, want 11", i)
+	}
+	removed := append(append([]string(nil), lines[:20]...), lines[21:]...)
+	if i := verifyChain(t, removed); i != 20 {
+		t.Errorf("removed line detected at %d, want 20", i)
+	}
+}
@@ -125,6 +125,8 @@ type (
 	Color struct {
 		output   io.Writer
 		disabled bool
+		// off is set by Disable, SetOutput then keeps colors disabled.
+		off bool
 	}
 )
 
@@ -140,22 +142,22 @@ func (c *Color) Output() io.Writer {
 	return c.output
 }
 
-// SetOutput sets the output.
+// SetOutput sets the output. Colors are enabled when w is a terminal and
+// disabled otherwise, or stay disabled after Disable.
 func (c *Color) SetOutput(w io.Writer) {
 	c.output = w
-	if w, ok := w.(*os.File); !ok || !isatty.IsTerminal(w.Fd()) {
-		c.disabled = true
-	}
+	f, ok := w.(*os.File)
+	c.disabled = c.off || !ok || !isatty.IsTerminal(f.Fd())
 }
 
-// Disable disables the colors and styles.
+// Disable disables the colors and styles until Enable is called.
 func (c *Color) Disable() {
-	c.disabled = true
+	c.disabled, c.off = true, true
 }
 
 // Enable enables the colors and styles.
 func (c *Color) Enable() {
-	c.disabled = false
+	c.disabled, c.off = false, false
 }
 
 // Print is analogous to `fmt.Print` with termial detection.
@@ -405,3 +407,8 @@ func Hidden(msg interface{}, styles ...string) string {
 func Strikeout(msg interface{}, styles ...string) string {
 	return global.Strikeout(msg, styles...)
 }
+
+// Enabled reports whether colors and styles are written.
+func (c *Color) Enabled() bool {
+	return !c.disabled
+}
@@ -0,0 +1,34 @@
+package color
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestSetOutputKeepsDisable(t *testing.T) {
+	c := New()
+	c.Disable()
+	for _, w := range []io.Writer{new(bytes.Buffer), os.Stdout} {
+		c.SetOutput(w)
+		if c.Enabled() {
+			t.Errorf("SetOutput(%T) enabled colors after Disable", w)
+		}
+	}
+	if got := c.Red("x"); got != "x" {
+		t.Errorf("Red = %q, want no escape codes", got)
+	}
+}
+
+func TestEnable(t *testing.T) {
+	c := New()
+	c.SetOutput(new(bytes.Buffer))
+	if c.Enabled() {
+		t.Error("colors enabled for a buffer")
+	}
+	c.Enable()
+	if got := c.Red("x"); got == "x" {
+		t.Error("Red after Enable has no escape codes")
+	}
+}
@@ -0,0 +1,81 @@
+package glog
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zt-tech/glog/color"
+)
+
+// ConsoleFormat is a human readable format for local development, similar
+// to gin's own logger:
+//
+//	2024-01-02 15:04:05 | 200 |      1.2ms |        10.0.0.1 | GET     /users?id=3
+//
+// Use it with ConsoleTimeFormat as CustomTimeFormat, see ConsoleLogger.
+const ConsoleFormat = "${time_custom} | ${status} | ${latency_console} | ${remote_ip_console} | ${method_color} ${uri}${extras}\n"
+
+// ConsoleTimeFormat is the CustomTimeFormat used by ConsoleLogger.
+const ConsoleTimeFormat = "2006-01-02 15:04:05"
+
+// ConsoleLogger returns a Logger middleware writing ConsoleFormat lines.
+func ConsoleLogger() gin.HandlerFunc {
+	return LoggerWithConfig(consoleConfig())
+}
+
+// consoleConfig is the config of ConsoleLogger. The line is not JSON, so
+// the tags are not escaped and the colors of method_color survive.
+func consoleConfig() LoggerConfig {
+	config := DefaultLoggerConfig
+	config.Format = ConsoleFormat
+	config.CustomTimeFormat = ConsoleTimeFormat
+	config.EscapeJSON = false
+	return config
+}
+
+// colorMethod returns method padded for alignment and colored per verb.
+func colorMethod(c *color.Color, method string) string {
+	s := fmt.Sprintf("%-7s", method)
+	switch method {
+	case http.MethodGet:
+		return c.Blue(s)
+	case http.MethodPost:
+		return c.Cyan(s)
+	case http.MethodPut:
+		return c.Yellow(s)
+	case http.MethodDelete:
+		return c.Red(s)
+	case http.MethodPatch:
+		return c.Green(s)
+	case http.MethodHead:
+		return c.Magenta(s)
+	}
+	return c.White(s)
+}
+
+// writeExtras writes " | key=value" for the level, error and app_id of the
+// request when they carry information: the level unless "info", the others
+// unless empty.
+func writeExtras(buf *bytes.Buffer, level Level, errInfo, appID string) (int, error) {
+	n := buf.Len()
+	sep := " | "
+	add := func(key, value string) {
+		buf.WriteString(sep)
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		sep = " "
+	}
+	if level != LevelInfo {
+		add("level", level.String())
+	}
+	if errInfo != "" {
+		add("error", fmt.Sprintf("%q", errInfo))
+	}
+	if appID != "" {
+		add("app_id", appID)
+	}
+	return buf.Len() - n, nil
+}
@@ -0,0 +1,49 @@
+package glog
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConsoleFormat(t *testing.T) {
+	for _, force := range []bool{false, true} {
+		config := consoleConfig()
+		config.Output = nil
+		config.ForceColor = force
+		r, out, _ := newTestRouter(t, config)
+		r.GET("/ok", func(ctx *gin.Context) {})
+		r.POST("/fail", func(ctx *gin.Context) {
+			ctx.Set(ContextAppID, "shop")
+			ctx.Error(errors.New(`bad "id"`))
+			ctx.Status(http.StatusInternalServerError)
+		})
+		serve(r, "GET", "/ok?id=3", nil)
+		serve(r, "POST", "/fail", nil)
+		serve(r, "DELETE", "/missing", nil)
+		lines := out.Lines()
+		if len(lines) != 3 {
+			t.Fatalf("got %d lines, want 3:\n%s", len(lines), out)
+		}
+		for _, line := range lines {
+			if strings.Contains(line, "${") || strings.Contains(line, `\u00`) {
+				t.Errorf("ForceColor %v: line %q has a raw placeholder or escaped text", force, line)
+			}
+			if colored := strings.Contains(line, "\x1b["); colored != force {
+				t.Errorf("ForceColor %v: line %q colored = %v", force, line, colored)
+			}
+		}
+		if !strings.HasSuffix(lines[0], "/ok?id=3") {
+			t.Errorf("info line %q has extras", lines[0])
+		}
+		if !strings.HasSuffix(lines[1], ` | level=error error="bad \"id\"" app_id=shop`) {
+			t.Errorf("error line = %q", lines[1])
+		}
+		if !strings.HasSuffix(lines[2], " | level=warn") {
+			t.Errorf("404 line = %q", lines[2])
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package glog
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextDependencies holds the dependency timings recorded by
+// RecordDependency.
+const ContextDependencies = "context_dependencies"
+
+type (
+	// DependencyTiming is the duration of one call to a downstream
+	// dependency made while handling a request.
+	DependencyTiming struct {
+		Name     string
+		Duration time.Duration
+	}
+
+	// slowDependencyLine is the warning written for a slow dependency.
+	slowDependencyLine struct {
+		Time          string `json:"time"`
+		Level         Level  `json:"level"`
+		ID            string `json:"id"`
+		Method        string `json:"method"`
+		URI           string `json:"uri"`
+		Dependency    string `json:"dependency"`
+		Duration      int64  `json:"duration"`
+		DurationHuman string `json:"duration_human"`
+		Threshold     int64  `json:"threshold"`
+	}
+)
+
+// RecordDependency records that a call to the dependency name took d. When
+// d exceeds LoggerConfig.DependencyThreshold a separate warning line is
+// logged for it.
+//
+//	start := time.Now()
+//	rows, err := db.Query(...)
+//	glog.RecordDependency(ctx, "mysql", time.Since(start))
+func RecordDependency(ctx *gin.Context, name string, d time.Duration) {
+	timings, _ := ctx.Get(ContextDependencies)
+	list, _ := timings.([]DependencyTiming)
+	ctx.Set(ContextDependencies, append(list, DependencyTiming{Name: name, Duration: d}))
+}
+
+// dependencyThreshold returns the threshold of the dependency name, 0 when
+// it is not watched.
+func (config *LoggerConfig) dependencyThreshold(name string) time.Duration {
+	if d, ok := config.DependencyThresholds[name]; ok {
+		return d
+	}
+	return config.DependencyThreshold
+}
+
+// logSlowDependencies writes one warning line per recorded dependency
+// slower than its threshold.
+func (config *LoggerConfig) logSlowDependencies(ctx *gin.Context, requestID string, now time.Time) {
+	timings, _ := ctx.Get(ContextDependencies)
+	list, _ := timings.([]DependencyTiming)
+	for _, t := range list {
+		threshold := config.dependencyThreshold(t.Name)
+		if threshold <= 0 || t.Duration <= threshold {
+			continue
+		}
+		config.writeSide(LevelWarn, slowDependencyLine{
+			Time:          now.Format(time.RFC3339Nano),
+			Level:         LevelWarn,
+			ID:            requestID,
+			Method:        ctx.Request.Method,
+			URI:           ctx.Request.RequestURI,
+			Dependency:    t.Name,
+			Duration:      int64(t.Duration),
+			DurationHuman: t.Duration.String(),
+			Threshold:     int64(threshold),
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package glog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDependencyWarning(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format:               "${path}\n",
+		DependencyThreshold:  100 * time.Millisecond,
+		DependencyThresholds: map[string]time.Duration{"cache": 10 * time.Millisecond},
+	})
+	r.GET("/", func(ctx *gin.Context) {
+		RecordDependency(ctx, "mysql", 100*time.Millisecond)
+		RecordDependency(ctx, "cache", 5*time.Millisecond)
+		RecordDependency(ctx, "redis", 150*time.Millisecond)
+		RecordDependency(ctx, "cache", 20*time.Millisecond)
+	})
+	serve(r, "GET", "/", nil, "X-Request-ID", "r1")
+	lines := out.Lines()
+	if len(lines) != 3 {
+		t.Fatalf("lines = %q, want two warnings and the entry", lines)
+	}
+	for i, want := range []struct {
+		dependency string
+		threshold  time.Duration
+	}{{"redis", 100 * time.Millisecond}, {"cache", 10 * time.Millisecond}} {
+		p := parsePairs(lines[i])
+		if p["level"] != "warn" || p["dependency"] != want.dependency || p["id"] != "r1" ||
+			p["threshold"] != strconv.FormatInt(int64(want.threshold), 10) {
+			t.Errorf("warning %d = %q", i, lines[i])
+		}
+	}
+	if lines[2] != "/" {
+		t.Errorf("entry = %q", lines[2])
+	}
+}
+
+func TestDependencyWarningNotExceeded(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${path}\n"})
+	r.GET("/", func(ctx *gin.Context) {
+		RecordDependency(ctx, "mysql", time.Hour)
+	})
+	serve(r, "GET", "/", nil)
+	if lines := out.Lines(); len(lines) != 1 {
+		t.Errorf("lines = %q, want only the entry without DependencyThreshold", lines)
+	}
+}
+
+func TestSideLineEncoding(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Fields:              map[string]string{"request_path": "path", "prev_hash": "prev_hash"},
+		KeyCase:             "camel",
+		TamperEvident:       true,
+		DependencyThreshold: time.Millisecond,
+		LogStartupMarker:    true,
+	})
+	r.GET("/", func(ctx *gin.Context) {
+		RecordDependency(ctx, "mysql", time.Second)
+	})
+	serve(r, "GET", "/", nil)
+	serve(r, "GET", "/", nil)
+	lines := out.Lines()
+	if len(lines) != 5 {
+		t.Fatalf("lines = %q, want the marker and two warnings and entries", lines)
+	}
+	var prev [sha256.Size]byte
+	for i, line := range lines {
+		var v map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			t.Fatalf("line %d %q: %v", i, line, err)
+		}
+		if v["prevHash"] != hex.EncodeToString(prev[:]) {
+			t.Errorf("line %d prevHash = %v, want %x", i, v["prevHash"], prev)
+		}
+		if _, ok := v["prev_hash"]; ok {
+			t.Errorf("line %d has a key not converted by KeyCase: %q", i, line)
+		}
+		h := sha256.New()
+		h.Write(prev[:])
+		h.Write([]byte(line + "\n"))
+		h.Sum(prev[:0])
+	}
+	for _, i := range []int{1, 3} {
+		var v map[string]interface{}
+		json.Unmarshal([]byte(lines[i]), &v)
+		if v["dependency"] != "mysql" || v["durationHuman"] != "1s" {
+			t.Errorf("warning %q", lines[i])
+		}
+	}
+}
+
+func TestSideLineMinLevel(t *testing.T) {
+	extra := new(syncBuffer)
+	out := new(syncBuffer)
+	r, _, _ := newTestRouter(t, LoggerConfig{
+		Outputs:             []io.Writer{out, extra},
+		Format:              "${path} ${status}\n",
+		MinLevel:            "error",
+		DependencyThreshold: time.Millisecond,
+		LogStartupConfig:    true,
+	})
+	r.GET("/", func(ctx *gin.Context) {
+		RecordDependency(ctx, "mysql", time.Second)
+		ctx.Status(500)
+	})
+	serve(r, "GET", "/", nil)
+	for _, w := range []*syncBuffer{out, extra} {
+		if got := w.Lines(); len(got) != 1 || got[0] != "/ 500" {
+			t.Errorf("lines = %q, want only the error entry", got)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package glog
+
+import (
+	"io"
+	"sync"
+)
+
+// diagnostics reports runtime misconfigurations once each. Conditions that
+// were already reported cost a single map lookup.
+type diagnostics struct {
+	out  io.Writer
+	seen sync.Map
+
+	mu       sync.Mutex
+	warnings []string
+}
+
+// warnOnce records msg and writes it to out the first time key is seen.
+func (d *diagnostics) warnOnce(key, msg string) {
+	if _, loaded := d.seen.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	d.mu.Lock()
+	d.warnings = append(d.warnings, msg)
+	d.mu.Unlock()
+	if d.out != nil {
+		_, _ = io.WriteString(d.out, "glog: "+msg+"\n")
+	}
+}
+
+// list returns a copy of the warnings reported so far.
+func (d *diagnostics) list() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.warnings...)
+}
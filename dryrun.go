@@ -0,0 +1,55 @@
+package glog
+
+import (
+	"strings"
+	"sync"
+)
+
+// dryRunBuffer keeps the last lines written to it, replacing the outputs
+// in DryRun mode.
+type dryRunBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	// next is the index of the oldest line once lines is full.
+	next int
+	size int
+}
+
+func newDryRunBuffer(size int) *dryRunBuffer {
+	return &dryRunBuffer{lines: make([]string, 0, size), size: size}
+}
+
+func (b *dryRunBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, line := range strings.SplitAfter(string(p), "\n") {
+		if line == "" {
+			continue
+		}
+		if len(b.lines) < b.size {
+			b.lines = append(b.lines, line)
+			continue
+		}
+		b.lines[b.next] = line
+		b.next = (b.next + 1) % b.size
+	}
+	return len(p), nil
+}
+
+// list returns the kept lines, oldest first.
+func (b *dryRunBuffer) list() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]string, 0, len(b.lines))
+	out = append(out, b.lines[b.next:]...)
+	return append(out, b.lines[:b.next]...)
+}
+
+// DryRunLines returns the last lines the middleware would have written, oldest
+// first, in DryRun mode. It returns nil otherwise.
+func (m *Middleware) DryRunLines() []string {
+	if m.config.dryRun == nil {
+		return nil
+	}
+	return m.config.dryRun.list()
+}
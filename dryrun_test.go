@@ -0,0 +1,49 @@
+package glog
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDryRun(t *testing.T) {
+	out, extra, errOut := new(syncBuffer), new(syncBuffer), new(syncBuffer)
+	r, _, m := newTestRouter(t, LoggerConfig{
+		Format:      "${path}\n",
+		Outputs:     []io.Writer{out, extra},
+		ErrorOutput: errOut,
+		DryRun:      true,
+		DryRunSize:  3,
+	})
+	r.GET("/:n", func(ctx *gin.Context) {
+		if ctx.Param("n") == "4" {
+			ctx.Status(http.StatusInternalServerError)
+		}
+	})
+	for i := 0; i < 5; i++ {
+		serve(r, "GET", "/"+strconv.Itoa(i), nil)
+		if i == 0 {
+			if got := m.DryRunLines(); !reflect.DeepEqual(got, []string{"/0\n"}) {
+				t.Errorf("DryRunLines = %q, want /0", got)
+			}
+		}
+	}
+	// The error entry is also copied for ErrorOutput.
+	if got, want := m.DryRunLines(), []string{"/3\n", "/4\n", "/4\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DryRunLines = %q, want %q", got, want)
+	}
+	for name, w := range map[string]*syncBuffer{"Outputs[0]": out, "Outputs[1]": extra, "ErrorOutput": errOut} {
+		if w.String() != "" {
+			t.Errorf("%s received %q in DryRun mode", name, w)
+		}
+	}
+
+	_, _, m = newTestRouter(t, LoggerConfig{})
+	if m.DryRunLines() != nil {
+		t.Error("DryRunLines is not nil without DryRun")
+	}
+}
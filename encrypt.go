@@ -0,0 +1,214 @@
+package glog
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// envelopePrefix starts every encrypted field value. The value continues
+// with the key id, a colon and base64(nonce || ciphertext).
+const envelopePrefix = "enc:"
+
+// fieldEncrypter encrypts the values of EncryptFields with an AES-GCM key
+// generated for the process. The key is only ever written wrapped with
+// EncryptionKey.
+type fieldEncrypter struct {
+	pub    *rsa.PublicKey
+	fields map[string]struct{}
+
+	mu    sync.RWMutex
+	aead  cipher.AEAD
+	keyID string
+}
+
+// keyLine is the header line announcing a wrapped key.
+type keyLine struct {
+	Message    string `json:"msg"`
+	KeyID      string `json:"key_id"`
+	WrappedKey string `json:"wrapped_key"`
+}
+
+func newFieldEncrypter(pub *rsa.PublicKey, fields []string) *fieldEncrypter {
+	e := &fieldEncrypter{pub: pub, fields: make(map[string]struct{}, len(fields))}
+	for _, f := range fields {
+		e.fields[f] = struct{}{}
+	}
+	return e
+}
+
+// rotate replaces the key and returns the header line announcing it.
+func (e *fieldEncrypter) rotate() (*keyLine, error) {
+	key := make([]byte, 32)
+	if _, err := crand.Read(key); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), crand.Reader, e.pub, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	id := keyID(wrapped)
+	e.mu.Lock()
+	e.aead, e.keyID = aead, id
+	e.mu.Unlock()
+	return &keyLine{
+		Message:    "glog key",
+		KeyID:      id,
+		WrappedKey: base64.StdEncoding.EncodeToString(wrapped),
+	}, nil
+}
+
+// encrypted reports whether the values of tag are encrypted.
+func (config *LoggerConfig) encrypted(tag string) bool {
+	if config.encrypter == nil {
+		return false
+	}
+	_, ok := config.encrypter.fields[tag]
+	return ok
+}
+
+// encrypt returns the envelope of plaintext.
+func (e *fieldEncrypter) encrypt(plaintext []byte) (string, error) {
+	e.mu.RLock()
+	aead, id := e.aead, e.keyID
+	e.mu.RUnlock()
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := crand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return envelopePrefix + id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext of an encrypted field value. wrappedKey is
+// the wrapped_key of the "glog key" line whose key_id the envelope names,
+// priv the private key matching LoggerConfig.EncryptionKey.
+func Decrypt(priv *rsa.PrivateKey, wrappedKey, envelope string) (string, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(wrappedKey)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(envelope, envelopePrefix) {
+		return "", errors.New("glog: not an encrypted value")
+	}
+	parts := strings.SplitN(envelope[len(envelopePrefix):], ":", 2)
+	if len(parts) != 2 {
+		return "", errors.New("glog: malformed encrypted value")
+	}
+	if parts[0] != keyID(wrapped) {
+		return "", errors.New("glog: encrypted value uses another key")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	key, err := rsa.DecryptOAEP(sha256.New(), crand.Reader, priv, wrapped, nil)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("glog: malformed encrypted value")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyID identifies a wrapped key by the first bytes of its hash.
+func keyID(wrapped []byte) string {
+	sum := sha256.Sum256(wrapped)
+	return hex.EncodeToString(sum[:8])
+}
+
+// writeKey writes a "glog key" line to Output and every other writer that
+// can receive encrypted values, once per writer. Unlike the other side
+// lines it ignores MinLevel: the entries cannot be decrypted without it.
+func (config *LoggerConfig) writeKey(k *keyLine) {
+	if config.chain != nil {
+		config.chain.mu.Lock()
+		defer config.chain.mu.Unlock()
+	}
+	line, err := config.sideLine(k)
+	if err != nil {
+		return
+	}
+	config.write(line)
+	if config.chain != nil {
+		defer config.chain.link(line)
+	}
+	written := []io.Writer{config.Output}
+	if config.async != nil {
+		written = append(written, config.async)
+	}
+	for _, o := range config.extraOutputs {
+		config.writeExtra(o, line)
+		written = append(written, o.w)
+	}
+	sides := []io.Writer{config.ErrorOutput, config.AbortedOutput}
+	if config.shadowTemplate != nil {
+		sides = append(sides, config.ShadowOutput)
+	}
+	for _, w := range sides {
+		if w == nil || containsWriter(written, w) {
+			continue
+		}
+		config.writeTo(w, line, config.outputFailures)
+		written = append(written, w)
+	}
+}
+
+// containsWriter reports whether w is one of ws. Writers of types that
+// cannot be compared are never found.
+func containsWriter(ws []io.Writer, w io.Writer) bool {
+	if !reflect.TypeOf(w).Comparable() {
+		return false
+	}
+	for _, x := range ws {
+		if reflect.TypeOf(x) == reflect.TypeOf(w) && x == w {
+			return true
+		}
+	}
+	return false
+}
+
+// RotateKey replaces the key encrypting EncryptFields and writes a new
+// "glog key" line to every writer receiving entries, e.g. after the log
+// file was rotated. It is a no-op without EncryptFields.
+func (m *Middleware) RotateKey() error {
+	if m.config.encrypter == nil {
+		return nil
+	}
+	line, err := m.config.encrypter.rotate()
+	if err != nil {
+		return err
+	}
+	m.config.writeKey(line)
+	return nil
+}
@@ -0,0 +1,166 @@
+package glog
+
+import (
+	crand "crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	testKeyOnce sync.Once
+	testKey     *rsa.PrivateKey
+)
+
+// privateKey returns an RSA key shared by the tests.
+func privateKey(t *testing.T) *rsa.PrivateKey {
+	testKeyOnce.Do(func() {
+		var err error
+		if testKey, err = rsa.GenerateKey(crand.Reader, 2048); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return testKey
+}
+
+// splitKeyLines returns the wrapped keys of the "glog key" lines of out
+// and the other lines.
+func splitKeyLines(t *testing.T, out *syncBuffer) (keys []keyLine, entries []string) {
+	t.Helper()
+	for _, line := range out.Lines() {
+		if pairs := parsePairs(line); pairs["msg"] == "glog key" {
+			keys = append(keys, keyLine{Message: pairs["msg"], KeyID: pairs["key_id"], WrappedKey: pairs["wrapped_key"]})
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return keys, entries
+}
+
+func TestDecrypt(t *testing.T) {
+	priv := privateKey(t)
+	errOut := new(syncBuffer)
+	r, out, m := newTestRouter(t, LoggerConfig{
+		Format:        "${status} ${body} ${context:user}\n",
+		EncryptFields: []string{"body", "context:user"},
+		EncryptionKey: &priv.PublicKey,
+		ErrorOutput:   errOut,
+	})
+	r.POST("/", func(ctx *gin.Context) {
+		ctx.Set("user", "alice")
+		ctx.Status(http.StatusInternalServerError)
+	})
+	decryptEntries := func(out *syncBuffer) []string {
+		keys, entries := splitKeyLines(t, out)
+		if len(keys) == 0 {
+			t.Fatal("no key line")
+		}
+		key := keys[len(keys)-1]
+		var plain []string
+		for _, e := range entries {
+			fields := strings.Fields(e)
+			for _, v := range fields[1:] {
+				if !strings.HasPrefix(v, envelopePrefix+key.KeyID+":") {
+					t.Fatalf("%q is not sealed with key %s", v, key.KeyID)
+				}
+				p, err := Decrypt(priv, key.WrappedKey, v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				plain = append(plain, p)
+			}
+		}
+		return plain
+	}
+
+	serve(r, "POST", "/", strings.NewReader(`{"card":"4111"}`))
+	for _, w := range []*syncBuffer{out, errOut} {
+		got := decryptEntries(w)
+		if len(got) != 2 || got[0] != `{"card":"4111"}` || got[1] != "alice" {
+			t.Errorf("decrypted %q", got)
+		}
+	}
+
+	keys, _ := splitKeyLines(t, out)
+	if err := m.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	serve(r, "POST", "/", strings.NewReader("second"))
+	rotated, entries := splitKeyLines(t, out)
+	if len(rotated) != 2 || rotated[1].KeyID == keys[0].KeyID {
+		t.Fatalf("key lines after RotateKey: %+v", rotated)
+	}
+	last := strings.Fields(entries[len(entries)-1])[1]
+	if _, err := Decrypt(priv, keys[0].WrappedKey, last); err == nil {
+		t.Error("value sealed after RotateKey decrypted with the old key")
+	}
+	if p, err := Decrypt(priv, rotated[1].WrappedKey, last); err != nil || p != "second" {
+		t.Errorf("Decrypt = %q, %v, want second", p, err)
+	}
+}
+
+func TestDecryptMalformed(t *testing.T) {
+	priv := privateKey(t)
+	e := newFieldEncrypter(&priv.PublicKey, nil)
+	key, err := e.rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := e.encrypt([]byte("v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{
+		"v",
+		envelopePrefix + key.KeyID,
+		envelopePrefix + key.KeyID + ":!!",
+		envelopePrefix + key.KeyID + ":AAAA",
+		sealed[:len(sealed)-4] + "AAAA",
+	} {
+		if _, err := Decrypt(priv, key.WrappedKey, v); err == nil {
+			t.Errorf("Decrypt(%q) succeeded", v)
+		}
+	}
+}
+
+func TestEncryptFieldsValidation(t *testing.T) {
+	priv := privateKey(t)
+	_, err := newMiddleware(LoggerConfig{
+		Output:        new(syncBuffer),
+		Format:        "${status}\n",
+		EncryptFields: []string{"body"},
+		EncryptionKey: &priv.PublicKey,
+	})
+	if err == nil {
+		t.Error("EncryptFields naming a tag missing from the format accepted")
+	}
+}
+
+func TestEncryptionKeyRequired(t *testing.T) {
+	out := new(syncBuffer)
+	before := runtime.NumGoroutine()
+	_, err := newMiddleware(LoggerConfig{
+		Output:           out,
+		Format:           "${body}\n",
+		EncryptFields:    []string{"body"},
+		Async:            true,
+		LogStartupConfig: true,
+		LogStartupMarker: true,
+	})
+	if err == nil {
+		t.Fatal("EncryptFields without EncryptionKey accepted")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if out.String() != "" {
+		t.Errorf("output = %q, want nothing written by a rejected config", out)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines left by a rejected config", n-before)
+	}
+}
@@ -0,0 +1,66 @@
+package glog
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// writeJSONEscaped writes s escaped for the inside of a JSON string: quotes,
+// backslashes and control characters are escaped, invalid UTF-8 is replaced
+// with U+FFFD and other characters are kept as is.
+func writeJSONEscaped(buf *bytes.Buffer, s []byte) {
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			buf.Write(s[start:i])
+			switch c {
+			case '"', '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case '\n':
+				buf.WriteString(`\n`)
+			case '\r':
+				buf.WriteString(`\r`)
+			case '\t':
+				buf.WriteString(`\t`)
+			default:
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRune(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.Write(s[start:i])
+			buf.WriteString(`�`)
+			i++
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf.Write(s[start:])
+}
+
+// escapedTag reports whether the value of tag is escaped by EscapeJSON:
+// every tag but the numeric and JSON valued ones.
+func escapedTag(tag string) bool {
+	if tag == "status" {
+		return false
+	}
+	if _, ok := numericTags[tag]; ok {
+		return false
+	}
+	_, ok := objectTags[tag]
+	return !ok
+}
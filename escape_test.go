@@ -0,0 +1,74 @@
+package glog
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEscapeJSON(t *testing.T) {
+	body := `{"msg":"he said \"hi\"\nline2"}`
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format:     `{"status":${status},"bytes_in":${bytes_in},"latency":${latency},"error":"${error}","ua":"${user_agent}","body":"${body}","raw":"${response}"}` + "\n",
+		EscapeJSON: true,
+	})
+	r.POST("/", func(ctx *gin.Context) {
+		ctx.Error(errors.New("bad \"input\"\n\t\x00é\xff"))
+		ctx.String(http.StatusBadRequest, "not\n\"json\\")
+	})
+	serve(r, "POST", "/", strings.NewReader(body), "User-Agent", "curl \"7\"\x1b[31m")
+	var entry struct {
+		Status  int
+		BytesIn int `json:"bytes_in"`
+		Latency int64
+		Error   string
+		UA      string
+		Body    string
+		Raw     string
+	}
+	if err := json.Unmarshal([]byte(out.String()), &entry); err != nil {
+		t.Fatalf("entry is not JSON: %v\n%s", err, out)
+	}
+	if entry.Status != 400 || entry.BytesIn != len(body) {
+		t.Errorf("status, bytes_in = %d, %d", entry.Status, entry.BytesIn)
+	}
+	if entry.Body != body {
+		t.Errorf("body = %q, want %q", entry.Body, body)
+	}
+	if entry.Error != "bad \"input\"\n\t\x00é\ufffd" {
+		t.Errorf("error = %q", entry.Error)
+	}
+	if entry.UA != "curl \"7\"\x1b[31m" || entry.Raw != `not"json\` {
+		t.Errorf("user_agent, response = %q, %q", entry.UA, entry.Raw)
+	}
+}
+
+func TestZeroConfigJSON(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{})
+	r.GET("/", func(ctx *gin.Context) {})
+	serve(r, "GET", "/", nil, "User-Agent", `a"b\c`)
+	var entry struct {
+		ID        string
+		UserAgent string `json:"user_agent"`
+	}
+	if err := json.Unmarshal([]byte(out.String()), &entry); err != nil {
+		t.Fatalf("entry is not JSON: %v\n%s", err, out)
+	}
+	if entry.UserAgent != `a"b\c` {
+		t.Errorf("user_agent = %q", entry.UserAgent)
+	}
+	if len(entry.ID) != 36 {
+		t.Errorf("id = %q, want a generated UUID", entry.ID)
+	}
+
+	r, out, _ = newTestRouter(t, LoggerConfig{Format: `{"ua":"${user_agent}"}` + "\n"})
+	r.GET("/", func(ctx *gin.Context) {})
+	serve(r, "GET", "/", nil, "User-Agent", `a"b\c`)
+	if want := `{"ua":"a\"b\\c"}` + "\n"; out.String() != want {
+		t.Errorf("JSON format entry = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,93 @@
+package glog
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/zt-tech/glog/color"
+)
+
+// defaultEntry holds the values of the tags of DefaultLoggerConfig.Format
+// for the FastPath encoder.
+type defaultEntry struct {
+	ts        time.Time
+	id        string
+	remoteIP  string
+	host      string
+	method    string
+	uri       string
+	userAgent string
+	status    string
+	latency   time.Duration
+	bytesIn   int64
+	bytesOut  int
+}
+
+// encode writes e exactly as DefaultLoggerConfig.Format renders it for a
+// request without error, without going through the template. escape
+// applies EscapeJSON to the string values.
+func (e *defaultEntry) encode(buf *bytes.Buffer, escape bool) {
+	var scratch [64]byte
+	str := func(s string) {
+		if escape {
+			writeJSONEscaped(buf, []byte(s))
+			return
+		}
+		buf.WriteString(s)
+	}
+	buf.WriteString(`{"time":"`)
+	buf.Write(e.ts.AppendFormat(scratch[:0], time.RFC3339Nano))
+	buf.WriteString(`","id":"`)
+	str(e.id)
+	buf.WriteString(`","remote_ip":"`)
+	str(e.remoteIP)
+	buf.WriteString(`","host":"`)
+	str(e.host)
+	buf.WriteString(`","method":"`)
+	str(e.method)
+	buf.WriteString(`","uri":"`)
+	str(e.uri)
+	buf.WriteString(`","user_agent":"`)
+	str(e.userAgent)
+	buf.WriteString(`","status":`)
+	buf.WriteString(e.status)
+	buf.WriteString(`,"error":"","latency":`)
+	buf.Write(strconv.AppendInt(scratch[:0], int64(e.latency), 10))
+	buf.WriteString(`,"latency_human":"`)
+	buf.WriteString(e.latency.String())
+	buf.WriteString(`","bytes_in":`)
+	buf.Write(strconv.AppendInt(scratch[:0], e.bytesIn, 10))
+	buf.WriteString(`,"bytes_out":`)
+	buf.Write(strconv.AppendInt(scratch[:0], int64(e.bytesOut), 10))
+	buf.WriteString("}\n")
+}
+
+// fastPathEligible reports whether the entries of config may use the
+// FastPath encoder: FastPath is set and Format is the default one with no
+// tag rewritten by EncryptFields or the latency_human settings.
+func (config *LoggerConfig) fastPathEligible() bool {
+	if !config.FastPath || config.Fields != nil || config.Format != DefaultLoggerConfig.Format ||
+		config.LatencyUnit != 0 || config.ColorLatency {
+		return false
+	}
+	for tag := range config.tags {
+		if config.encrypted(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// statusText returns the status colored by class.
+func statusText(c *color.Color, n int) string {
+	switch {
+	case n >= 500:
+		return c.Red(n)
+	case n >= 400:
+		return c.Yellow(n)
+	case n >= 300:
+		return c.Cyan(n)
+	}
+	return c.Green(n)
+}
@@ -0,0 +1,125 @@
+package glog
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// steppingClock returns a clock starting at a fixed instant and advancing
+// by step on every call.
+func steppingClock(step time.Duration) func() time.Time {
+	var mu sync.Mutex
+	now := time.Date(2020, 1, 2, 3, 4, 5, 600, time.FixedZone("CET", 3600))
+	return func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(step)
+		return now
+	}
+}
+
+// defaultRouter returns a router logging with the default format, using
+// the fast path or the template.
+func defaultRouter(t testing.TB, config LoggerConfig, fast bool) (*gin.Engine, *syncBuffer) {
+	config.Format = DefaultLoggerConfig.Format
+	config.EscapeJSON = true
+	config.FastPath = fast
+	config.now = steppingClock(1234567 * time.Nanosecond)
+	r, out, m := newTestRouter(t, config)
+	if m.config.fastPath != fast {
+		t.Fatalf("fast path %v, want %v", m.config.fastPath, fast)
+	}
+	r.Any("/*path", func(ctx *gin.Context) {
+		status := http.StatusOK
+		switch ctx.Query("status") {
+		case "404":
+			status = http.StatusNotFound
+		case "500":
+			status = http.StatusInternalServerError
+		case "302":
+			status = http.StatusFound
+		}
+		if e := ctx.Query("err"); e != "" {
+			// Entries with an error fall back to the template.
+			ctx.Error(errors.New(e))
+		}
+		ctx.String(status, strings.Repeat("x", len(ctx.Request.URL.Path)))
+	})
+	return r, out
+}
+
+// golden lists the requests of the equivalence matrix: method, target,
+// body and header pairs.
+var golden = []struct {
+	method, target, body string
+	header               []string
+}{
+	{"GET", "/", "", nil},
+	{"GET", "/users/42?status=404", "", []string{"User-Agent", `curl/7.68 "quoted" \ back`}},
+	{"POST", "/orders?status=500", `{"a":1}`, []string{"X-Request-ID", "req-1"}},
+	{"PUT", "/redirect?status=302", "abc", []string{"X-Forwarded-For", "10.1.2.3"}},
+	{"DELETE", "/%E2%9C%93/\xff?q=%22x%22", "", []string{"User-Agent", "tab\tnewline\n\x01ctl"}},
+	{"PATCH", "/override", "", []string{"X-HTTP-Method-Override", "PURGE"}},
+	{"GET", "/host", "", []string{"Host", `evil"host`}},
+	{"GET", "/fail?status=500&err=%22boom%22", "", nil},
+}
+
+func TestFastPathGolden(t *testing.T) {
+	for i, config := range []LoggerConfig{
+		{},
+		{ForceColor: true},
+		{GenerateRequestID: true},
+		{TrustMethodOverride: true},
+		{TimeAtStart: true},
+		{MaskedHeaders: []string{}},
+	} {
+		fast, fastOut := defaultRouter(t, config, true)
+		slow, slowOut := defaultRouter(t, config, false)
+		for _, g := range golden {
+			if config.GenerateRequestID {
+				// Generated IDs differ, send the same one.
+				g.header = append(g.header, "X-Request-ID", "fixed")
+			}
+			serve(fast, g.method, g.target, strings.NewReader(g.body), g.header...)
+			serve(slow, g.method, g.target, strings.NewReader(g.body), g.header...)
+		}
+		got, want := fastOut.String(), slowOut.String()
+		if got != want {
+			t.Errorf("config %d: fast path\n%s\ntemplate\n%s", i, got, want)
+		}
+		if n := len(fastOut.Lines()); n != len(golden) {
+			t.Errorf("config %d: %d entries, want %d", i, n, len(golden))
+		}
+	}
+}
+
+func benchmarkDefaultFormat(b *testing.B, fast bool) {
+	config := LoggerConfig{Format: DefaultLoggerConfig.Format, EscapeJSON: true, FastPath: fast, Output: discard{}}
+	m, err := newMiddleware(config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(m.handle)
+	r.GET("/users/:id", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+	req, _ := http.NewRequest("GET", "/users/42?x=1", nil)
+	req.Header.Set("User-Agent", "bench")
+	w := new(nopWriter)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkFastPath(b *testing.B) { benchmarkDefaultFormat(b, true) }
+
+func BenchmarkTemplate(b *testing.B) { benchmarkDefaultFormat(b, false) }
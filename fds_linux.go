@@ -0,0 +1,19 @@
+package glog
+
+import "os"
+
+// openFDs returns the number of file descriptors open in this process,
+// or -1 when it cannot be determined.
+func openFDs() int {
+	f, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return -1
+	}
+	// Do not count the descriptor used to read the directory.
+	return len(names) - 1
+}
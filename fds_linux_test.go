@@ -0,0 +1,43 @@
+package glog
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenFDs(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${open_fds}\n", LogOpenFDs: true})
+	r.GET("/fail", func(ctx *gin.Context) { ctx.Status(http.StatusInternalServerError) })
+	r.GET("/ok", func(ctx *gin.Context) {})
+	// Open a few descriptors so the count is known to include them.
+	for i := 0; i < 3; i++ {
+		f, err := os.Open(os.DevNull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+	}
+	serve(r, "GET", "/fail", nil)
+	serve(r, "GET", "/ok", nil)
+	lines := out.Lines()
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	if n, err := strconv.Atoi(lines[0]); err != nil || n < 6 || n > 10000 {
+		t.Errorf("open_fds on an error line = %q, want a plausible count", lines[0])
+	}
+	if lines[1] != "-1" {
+		t.Errorf("open_fds on a 200 line = %q, want -1", lines[1])
+	}
+
+	r, out, _ = newTestRouter(t, LoggerConfig{Format: "${open_fds}\n"})
+	r.GET("/fail", func(ctx *gin.Context) { ctx.Status(http.StatusInternalServerError) })
+	serve(r, "GET", "/fail", nil)
+	if out.String() != "-1\n" {
+		t.Errorf("open_fds without LogOpenFDs = %q, want -1", out)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package glog
+
+// openFDs is not supported on this platform.
+func openFDs() int {
+	return -1
+}
@@ -0,0 +1,118 @@
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultStructuredFields is the field set used by LoggerConfig.Structured,
+// mirroring DefaultLoggerConfig.Format.
+var DefaultStructuredFields = map[string]string{
+	"time":          "time_rfc3339_nano",
+	"id":            "id",
+	"remote_ip":     "remote_ip",
+	"host":          "host",
+	"method":        "method",
+	"uri":           "uri",
+	"user_agent":    "user_agent",
+	"status":        "status",
+	"level":         "level",
+	"error":         "error",
+	"latency":       "latency",
+	"latency_human": "latency_human",
+	"bytes_in":      "bytes_in",
+	"bytes_out":     "bytes_out",
+	"seq":           "seq",
+}
+
+// numericTags are the tags encoded as JSON numbers by Fields.
+var numericTags = map[string]struct{}{
+	"time_unix":        {},
+	"time_unix_nano":   {},
+	"cookie_count":     {},
+	"seq":              {},
+	"sample_rate":      {},
+	"open_fds":         {},
+	"heap_alloc":       {},
+	"alloc_bytes":      {},
+	"bytes_in":         {},
+	"bytes_out":        {},
+	"bytes_sent":       {},
+	"latency":          {},
+	"severity_number":  {},
+	"level_value":      {},
+	"upstream_latency": {},
+}
+
+// objectTags are the tags that render a JSON value and are embedded as is
+// by Fields.
+var objectTags = map[string]struct{}{
+	"params_object":      {},
+	"middleware_timings": {},
+	"request_headers":    {},
+	"response_headers":   {},
+	"aborted":            {},
+	"status_explicit":    {},
+	"matched":            {},
+	"slow":               {},
+	"cors_allowed":       {},
+}
+
+// encodeFields renders config.Fields as a JSON object followed by a newline
+// into buf, resolving each tag with writeTag. The extra pairs, then the
+// StaticFields, are added unless a field of the same name exists. Names
+// are compared once converted per KeyCase; extra names converting to the
+// same key keep the first in sort order.
+func (config *LoggerConfig) encodeFields(buf *bytes.Buffer, writeTag func(*bytes.Buffer, string) (int, error), status int, extra map[string]string) error {
+	entry := make(map[string]interface{}, len(config.Fields)+len(extra))
+	var tmp bytes.Buffer
+	for name, tag := range config.Fields {
+		key := config.casedKey(name)
+		switch tag {
+		case "status":
+			// Never colored in structured output.
+			entry[key] = status
+			continue
+		}
+		tmp.Reset()
+		if _, err := writeTag(&tmp, tag); err != nil {
+			return err
+		}
+		if config.encrypted(tag) {
+			entry[key] = tmp.String()
+		} else if _, ok := numericTags[tag]; ok && tmp.Len() > 0 {
+			entry[key] = json.Number(tmp.String())
+		} else if _, ok := objectTags[tag]; ok && tmp.Len() > 0 {
+			entry[key] = json.RawMessage(append([]byte(nil), tmp.Bytes()...))
+		} else {
+			entry[key] = tmp.String()
+		}
+	}
+	var extraNames map[string]string
+	for name, value := range extra {
+		key := config.casedKey(name)
+		if other, ok := extraNames[key]; ok {
+			config.diag.warnOnce("keycase:"+key, fmt.Sprintf("route info keys %q and %q are both %q with KeyCase %q", other, name, key, config.KeyCase))
+			if other < name {
+				continue
+			}
+		} else if _, ok := entry[key]; ok {
+			continue
+		}
+		if extraNames == nil {
+			extraNames = make(map[string]string, len(extra))
+		}
+		extraNames[key] = name
+		entry[key] = value
+	}
+	for name, value := range config.staticFields {
+		key := config.casedKey(name)
+		if _, ok := entry[key]; !ok {
+			entry[key] = value
+		}
+	}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(entry)
+}
@@ -0,0 +1,86 @@
+package glog
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFields(t *testing.T) {
+	fields := map[string]string{
+		"status":    "status",
+		"latency":   "latency",
+		"bytes_out": "bytes_out",
+		"response":  "response",
+		"ua":        "user_agent",
+		"matched":   "matched",
+	}
+	r, out, _ := newTestRouter(t, LoggerConfig{Fields: fields, ForceColor: true})
+	r.GET("/", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "he said \"hi\"\r\nline2\t\x01")
+	})
+	serve(r, "GET", "/", nil, "User-Agent", `agent "x"`+"\n")
+	lines := out.Lines()
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %q", len(lines), out)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("entry is not JSON: %v\n%s", err, lines[0])
+	}
+	if entry["status"] != 200.0 || entry["bytes_out"] != 21.0 || entry["matched"] != true {
+		t.Errorf("status, bytes_out, matched = %#v, %#v, %#v, want numbers and a boolean", entry["status"], entry["bytes_out"], entry["matched"])
+	}
+	if _, ok := entry["latency"].(float64); !ok {
+		t.Errorf("latency = %#v, want a number", entry["latency"])
+	}
+	if s, _ := entry["response"].(string); !strings.Contains(s, `he said "hi"`) || !strings.Contains(s, "line2\t\x01") {
+		t.Errorf("response = %q", entry["response"])
+	}
+	if entry["ua"] != `agent "x"`+"\n" {
+		t.Errorf("ua = %q", entry["ua"])
+	}
+}
+
+func TestFieldsDefaultsToFormat(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${method} ${status}\n"})
+	r.GET("/", func(ctx *gin.Context) {})
+	serve(r, "GET", "/", nil)
+	if out.String() != "GET 200\n" {
+		t.Errorf("entry = %q, want the Format line", out)
+	}
+}
+
+func TestStructuredRoundTrip(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Structured: true, GenerateRequestID: true})
+	r.GET("/ok", func(ctx *gin.Context) { ctx.String(http.StatusOK, "ok") })
+	r.GET("/fail", func(ctx *gin.Context) {
+		ctx.Error(errors.New("bad \"input\"\n\x00\xff"))
+		ctx.Set(ContextError, map[string]int{"code": 7})
+		ctx.Status(http.StatusInternalServerError)
+	})
+	serve(r, "GET", "/ok", nil)
+	serve(r, "GET", "/ok?q=%22", nil, "User-Agent", "a\"b\\c\x01\xfe", "X-Request-ID", `"id"`)
+	serve(r, "GET", "/fail", nil)
+	serve(r, "POST", "/missing", strings.NewReader("x"))
+	lines := out.Lines()
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4", len(lines))
+	}
+	for _, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Errorf("entry is not JSON: %v\n%s", err, line)
+			continue
+		}
+		for name := range DefaultStructuredFields {
+			if _, ok := entry[name]; !ok {
+				t.Errorf("entry misses %s: %s", name, line)
+			}
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package glog
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is appended to the file name of rotated files. It sorts
+// lexically in time order.
+const backupTimeFormat = "20060102-150405.000"
+
+type (
+	// FileWriter is an io.Writer appending to a file that is rotated by
+	// size. It is safe for concurrent use and every Write lands entirely
+	// in one file, so entries are never split across a rotation.
+	FileWriter struct {
+		mu         sync.Mutex
+		path       string
+		maxSize    int64
+		maxAge     time.Duration
+		maxBackups int
+		file       *os.File
+		size       int64
+		// stop ends the reopening of WithReopenOnSIGHUP.
+		stop func()
+	}
+
+	// FileOption configures a FileWriter.
+	FileOption func(*FileWriter)
+)
+
+// NewFileWriter opens path for appending, creating missing parent
+// directories, and returns a FileWriter usable as LoggerConfig.Output.
+func NewFileWriter(path string, opts ...FileOption) (*FileWriter, error) {
+	w := &FileWriter{path: path}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// WithMaxSize rotates the file before it grows beyond n bytes.
+func WithMaxSize(n int64) FileOption {
+	return func(w *FileWriter) {
+		w.maxSize = n
+	}
+}
+
+// WithMaxAge removes rotated files older than d.
+func WithMaxAge(d time.Duration) FileOption {
+	return func(w *FileWriter) {
+		w.maxAge = d
+	}
+}
+
+// WithMaxBackups keeps at most n rotated files.
+func WithMaxBackups(n int) FileOption {
+	return func(w *FileWriter) {
+		w.maxBackups = n
+	}
+}
+
+func (w *FileWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.size = f, info.Size()
+	return nil
+}
+
+// Write appends p to the file, rotating it first when p would exceed the
+// maximum size.
+func (w *FileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Reopen closes and reopens the file, e.g. after it was moved away by an
+// external tool.
+func (w *FileWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	return w.open()
+}
+
+// Close closes the file and stops WithReopenOnSIGHUP. A later Write opens
+// the file again.
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		w.stop()
+		w.stop = nil
+	}
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *FileWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	backup := w.path + "." + time.Now().Format(backupTimeFormat)
+	// Never overwrite a backup rotated within the same millisecond.
+	for i := 1; ; i++ {
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			break
+		}
+		backup = w.path + "." + time.Now().Format(backupTimeFormat) + "-" + strconv.Itoa(i)
+	}
+	if err := os.Rename(w.path, backup); err != nil {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.removeBackups()
+	return nil
+}
+
+// removeBackups deletes the rotated files beyond maxBackups or older than
+// maxAge.
+func (w *FileWriter) removeBackups() {
+	if w.maxBackups <= 0 && w.maxAge <= 0 {
+		return
+	}
+	backups, err := filepath.Glob(w.path + ".*")
+	if err != nil {
+		return
+	}
+	// Newest first.
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	cutoff := time.Now().Add(-w.maxAge)
+	for i, b := range backups {
+		stamp := strings.TrimPrefix(b, w.path+".")
+		if len(stamp) > len(backupTimeFormat) {
+			// Drop the suffix of same-millisecond backups.
+			stamp = stamp[:len(backupTimeFormat)]
+		}
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		remove := w.maxBackups > 0 && i >= w.maxBackups
+		if !remove && w.maxAge > 0 {
+			if info, err := os.Stat(b); err == nil && info.ModTime().Before(cutoff) {
+				remove = true
+			}
+		}
+		if remove {
+			os.Remove(b)
+		}
+	}
+}
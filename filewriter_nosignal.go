@@ -0,0 +1,9 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package glog
+
+// WithReopenOnSIGHUP does nothing on platforms without SIGHUP.
+func WithReopenOnSIGHUP() FileOption {
+	return func(w *FileWriter) {}
+}
@@ -0,0 +1,34 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package glog
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WithReopenOnSIGHUP reopens the file whenever the process receives SIGHUP,
+// for use with external log rotation, until the FileWriter is closed.
+func WithReopenOnSIGHUP() FileOption {
+	return func(w *FileWriter) {
+		c := make(chan os.Signal, 1)
+		done := make(chan struct{})
+		signal.Notify(c, syscall.SIGHUP)
+		go func() {
+			for {
+				select {
+				case <-c:
+					w.Reopen()
+				case <-done:
+					return
+				}
+			}
+		}()
+		w.stop = func() {
+			signal.Stop(c)
+			close(done)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package glog
+
+import (
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReopenOnSIGHUP(t *testing.T) {
+	// Keep SIGHUP from terminating the test once the writer stops
+	// listening.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	dir, err := ioutil.TempDir("", "glog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+	w, err := NewFileWriter(path, WithReopenOnSIGHUP())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	rotate := func(backup string, wait time.Duration) bool {
+		if err := os.Rename(path, filepath.Join(dir, backup)); err != nil {
+			t.Fatal(err)
+		}
+		syscall.Kill(os.Getpid(), syscall.SIGHUP)
+		<-hup
+		deadline := time.Now().Add(wait)
+		for time.Now().Before(deadline) {
+			if _, err := os.Stat(path); err == nil {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	w.Write([]byte("a\n"))
+	if !rotate("access.log.1", 2*time.Second) {
+		t.Fatal("file not reopened on SIGHUP")
+	}
+	w.Write([]byte("b\n"))
+	if b, _ := ioutil.ReadFile(path); string(b) != "b\n" {
+		t.Errorf("reopened file holds %q, want the later write", b)
+	}
+
+	w.Close()
+	if rotate("access.log.2", 200*time.Millisecond) {
+		t.Error("file reopened on SIGHUP after Close")
+	}
+}
@@ -0,0 +1,91 @@
+package glog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFileWriterRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logs", "app", "access.log")
+	w, err := NewFileWriter(path, WithMaxSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	r, _, _ := newTestRouter(t, LoggerConfig{Output: w})
+	r.GET("/:n", func(ctx *gin.Context) {})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				serve(r, "GET", "/"+strings.Repeat("x", j), nil)
+			}
+		}()
+	}
+	wg.Wait()
+	w.Close()
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) == 0 {
+		t.Fatal("no rotated file")
+	}
+	lines := 0
+	for _, name := range append(backups, path) {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 1024 {
+			t.Errorf("%s has %d bytes, more than the maximum size", name, len(data))
+		}
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			t.Errorf("%s ends with a partial line", name)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			var entry map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Errorf("%s: line %q is not JSON: %v", name, line, err)
+			}
+			lines++
+		}
+	}
+	if lines != 200 {
+		t.Errorf("got %d lines, want 200", lines)
+	}
+}
+
+func TestFileWriterMaxBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+	w, err := NewFileWriter(path, WithMaxSize(10), WithMaxBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	for i := 0; i < 10; i++ {
+		if _, err := w.Write([]byte("0123456789\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if backups, _ := filepath.Glob(path + ".*"); len(backups) != 2 {
+		t.Errorf("got %d backups, want 2", len(backups))
+	}
+}
@@ -0,0 +1,85 @@
+package glog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fingerprint returns a short hash over the normalized request components
+// listed in FingerprintFields. It is a heuristic for clustering similar
+// requests, not an identifier: unrelated clients behind the same /24 with
+// the same user agent family collide on purpose.
+func (config *LoggerConfig) fingerprint(ctx *gin.Context) string {
+	h := sha256.New()
+	for _, field := range config.FingerprintFields {
+		var v string
+		switch field {
+		case "method":
+			v = ctx.Request.Method
+		case "route":
+			if v = ctx.FullPath(); v == "" {
+				v = ctx.Request.URL.Path
+			}
+		case "query_names":
+			q := ctx.Request.URL.Query()
+			names := make([]string, 0, len(q))
+			for name := range q {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			v = strings.Join(names, ",")
+		case "ua_family":
+			v = userAgentFamily(ctx.Request.UserAgent())
+		case "ip_prefix":
+			v = ipPrefix(ctx.ClientIP())
+		}
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// userAgentFamily returns the lowercased product name of the last
+// significant product token, e.g. "chrome" or "curl".
+func userAgentFamily(ua string) string {
+	family := ""
+	for _, token := range strings.Fields(ua) {
+		i := strings.IndexByte(token, '/')
+		if i <= 0 {
+			continue
+		}
+		switch name := strings.ToLower(token[:i]); name {
+		case "mozilla", "applewebkit", "gecko", "version", "mobile", "khtml":
+			if family == "" {
+				family = name
+			}
+		case "safari":
+			// Chrome and Edge also send Safari, keep the more specific one.
+			if family == "" || family == "mozilla" || family == "applewebkit" || family == "version" {
+				family = name
+			}
+		default:
+			family = name
+		}
+	}
+	return family
+}
+
+// ipPrefix masks an IPv4 address to /24 and an IPv6 address to /48.
+func ipPrefix(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return s
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
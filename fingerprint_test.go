@@ -0,0 +1,31 @@
+package glog
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestFingerprint(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${request_fingerprint}\n"})
+	r.GET("/users/:id", func(ctx *gin.Context) {})
+	r.GET("/orders/:id", func(ctx *gin.Context) {})
+	ua := "Mozilla/5.0 (X11; Linux x86_64) Chrome/120.0 Safari/537.36"
+	serve(r, "GET", "/users/1?q=a&page=1", nil, "User-Agent", ua)
+	serve(r, "GET", "/users/2?page=9&q=zzz", nil, "User-Agent", ua)
+	serve(r, "GET", "/orders/1?q=a&page=1", nil, "User-Agent", ua)
+	serve(r, "GET", "/users/1?q=a", nil, "User-Agent", ua)
+	serve(r, "GET", "/users/1?q=a&page=1", nil, "User-Agent", "curl/8.0")
+	lines := out.Lines()
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 5", len(lines))
+	}
+	if len(lines[0]) != 16 || lines[0] != lines[1] {
+		t.Errorf("requests differing in values only: %q and %q, want the same fingerprint", lines[0], lines[1])
+	}
+	for i, what := range map[int]string{2: "path", 3: "query names", 4: "user agent"} {
+		if lines[i] == lines[0] {
+			t.Errorf("requests differing in %s share the fingerprint %q", what, lines[0])
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package glog
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LogEntry describes a handled request for LoggerConfig.Hook.
+type LogEntry struct {
+	Method string
+	Path   string
+	// Route is the route template, e.g. "/users/:id", empty when no
+	// route matched.
+	Route     string
+	Status    int
+	Latency   time.Duration
+	BytesIn   int64
+	BytesOut  int
+	ClientIP  string
+	Level     Level
+	Error     string
+	AppID     string
+	RequestID string
+	// Seq is the seq tag of the entry, 0 when the request was not logged.
+	Seq uint64
+	// Skipped is set for the requests matching Skip, SkipPaths or
+	// Skipper. Their bodies are not captured and RequestID is only the
+	// one the client sent.
+	Skipped bool
+}
+
+// hookSkipped runs the handlers of a request that is not logged and
+// reports it to Hook.
+func (config *LoggerConfig) hookSkipped(ctx *gin.Context) {
+	start := config.now()
+	ctx.Next()
+	latency := config.now().Sub(start)
+	slow := config.SlowThreshold > 0 && latency >= config.SlowThreshold
+	bytesIn := ctx.Request.ContentLength
+	if bytesIn < 0 {
+		bytesIn = 0
+	}
+	bytesOut := ctx.Writer.Size()
+	if bytesOut < 0 {
+		bytesOut = 0
+	}
+	config.runHook(LogEntry{
+		Method:    config.method(ctx.Request),
+		Path:      ctx.Request.URL.Path,
+		Route:     ctx.FullPath(),
+		Status:    ctx.Writer.Status(),
+		Latency:   latency,
+		BytesIn:   bytesIn,
+		BytesOut:  bytesOut,
+		ClientIP:  ctx.ClientIP(),
+		Level:     config.entryLevel(ctx, ctx.IsAborted(), slow),
+		Error:     errorText(ctx),
+		AppID:     contextValue(ctx, ContextAppID),
+		RequestID: ctx.Request.Header.Get(config.RequestIDHeader),
+		Skipped:   true,
+	})
+}
+
+// runHook calls Hook, recovering and reporting its panics.
+func (config *LoggerConfig) runHook(entry LogEntry) {
+	defer func() {
+		if r := recover(); r != nil {
+			config.diag.warnOnce("hook", fmt.Sprintf("hook panicked: %v", r))
+		}
+	}()
+	config.Hook(entry)
+}
@@ -0,0 +1,136 @@
+package glog
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hookRecorder collects the entries passed to Hook.
+type hookRecorder struct {
+	mu      sync.Mutex
+	entries []LogEntry
+}
+
+func (h *hookRecorder) hook(e LogEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, e)
+}
+
+func TestHook(t *testing.T) {
+	rec := new(hookRecorder)
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format:    "${path}\n",
+		Hook:      rec.hook,
+		MinStatus: 500,
+	})
+	r.POST("/users/:id", func(ctx *gin.Context) {
+		ctx.Set(ContextAppID, "app")
+		ctx.Error(errors.New("boom"))
+		ctx.String(http.StatusBadRequest, "nope")
+	})
+	serve(r, "POST", "/users/7", strings.NewReader("body"), "X-Request-ID", "r1")
+	if got := out.Lines(); len(got) != 0 {
+		t.Errorf("entries %q below MinStatus", got)
+	}
+	if len(rec.entries) != 1 {
+		t.Fatalf("hook called %d times, want once for a filtered request", len(rec.entries))
+	}
+	e := rec.entries[0]
+	if e.Method != "POST" || e.Path != "/users/7" || e.Route != "/users/:id" || e.Status != 400 ||
+		e.BytesIn != 4 || e.BytesOut != 4 || e.ClientIP != "192.0.2.1" || e.Level != LevelError ||
+		e.Error != "boom" || e.AppID != "app" || e.RequestID != "r1" || e.Skipped || e.Latency <= 0 {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+func TestHookPanic(t *testing.T) {
+	diag := new(syncBuffer)
+	r, out, m := newTestRouter(t, LoggerConfig{
+		Format:            "${path}\n",
+		Hook:              func(LogEntry) { panic("hook") },
+		DiagnosticsOutput: diag,
+	})
+	r.GET("/", func(ctx *gin.Context) {})
+	for i := 0; i < 2; i++ {
+		if w := serve(r, "GET", "/", nil); w.Code != http.StatusOK {
+			t.Errorf("status %d", w.Code)
+		}
+	}
+	if got := out.Lines(); len(got) != 2 {
+		t.Errorf("entries = %q", got)
+	}
+	if got := diag.Lines(); len(got) != 1 || !strings.Contains(got[0], "hook panicked") {
+		t.Errorf("diagnostics = %q, want one report", got)
+	}
+	if len(m.Stats().Warnings) != 1 {
+		t.Errorf("warnings = %q", m.Stats().Warnings)
+	}
+}
+
+func TestHookSkipped(t *testing.T) {
+	rec := new(hookRecorder)
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format:    "${path}\n",
+		Hook:      rec.hook,
+		SkipPaths: []string{"/health"},
+		Skipper:   func(ctx *gin.Context) bool { return ctx.Query("skip") != "" },
+	})
+	r.GET("/health", func(ctx *gin.Context) { ctx.String(http.StatusOK, "ok") })
+	r.GET("/", func(ctx *gin.Context) { ctx.Status(http.StatusServiceUnavailable) })
+	serve(r, "GET", "/health", nil, "X-Request-ID", "r1")
+	serve(r, "GET", "/?skip=1", nil)
+	serve(r, "GET", "/", nil)
+	if got := out.Lines(); len(got) != 1 || got[0] != "/" {
+		t.Errorf("entries = %q", got)
+	}
+	if len(rec.entries) != 3 {
+		t.Fatalf("hook called %d times, want 3", len(rec.entries))
+	}
+	if e := rec.entries[0]; !e.Skipped || e.Path != "/health" || e.Status != 200 || e.BytesOut != 2 || e.RequestID != "r1" || e.Level != LevelInfo {
+		t.Errorf("SkipPaths entry = %+v", e)
+	}
+	if e := rec.entries[1]; !e.Skipped || e.Status != 503 || e.Level != LevelError {
+		t.Errorf("Skipper entry = %+v", e)
+	}
+	if rec.entries[2].Skipped {
+		t.Error("logged request reported as skipped")
+	}
+}
+
+func TestHookSeq(t *testing.T) {
+	rec := new(hookRecorder)
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Structured: true,
+		Hook:       rec.hook,
+		MinStatus:  400,
+	})
+	r.GET("/:status", func(ctx *gin.Context) {
+		status, _ := strconv.Atoi(ctx.Param("status"))
+		ctx.Status(status)
+	})
+	for _, status := range []string{"404", "200", "500"} {
+		serve(r, "GET", "/"+status, nil)
+	}
+	var seqs []uint64
+	for _, e := range rec.entries {
+		seqs = append(seqs, e.Seq)
+	}
+	if !reflect.DeepEqual(seqs, []uint64{1, 0, 2}) {
+		t.Errorf("LogEntry.Seq = %v, want 1, 0 (not logged), 2", seqs)
+	}
+	for i, line := range out.Lines() {
+		var v struct{ Seq uint64 }
+		if err := json.Unmarshal([]byte(line), &v); err != nil || v.Seq != uint64(i+1) {
+			t.Errorf("entry %q: seq %d, want %d", line, v.Seq, i+1)
+		}
+	}
+}
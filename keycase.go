@@ -0,0 +1,100 @@
+package glog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// keyCaser returns the function converting the Fields keys to keyCase:
+// "snake" (request_id), "camel" (requestId) or "pascal" (RequestId). It
+// returns nil for "", keeping the keys as configured, and false for an
+// unknown case.
+func keyCaser(keyCase string) (func(string) string, bool) {
+	switch keyCase {
+	case "":
+		return nil, true
+	case "snake":
+		return func(key string) string {
+			return strings.Join(keyWords(key), "_")
+		}, true
+	case "camel", "pascal":
+		return func(key string) string {
+			words := keyWords(key)
+			for i, w := range words {
+				if i > 0 || keyCase == "pascal" {
+					r, size := utf8.DecodeRuneInString(w)
+					words[i] = string(unicode.ToUpper(r)) + w[size:]
+				}
+			}
+			return strings.Join(words, "")
+		}, true
+	}
+	return nil, false
+}
+
+// keyWords splits key into lowercase words on '_', '-', '.', spaces and
+// lower to upper case transitions, so "requestID", "request_id" and
+// "Request-Id" all give ["request", "id"].
+func keyWords(key string) []string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	runes := []rune(key)
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-' || r == '.' || unicode.IsSpace(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && len(word) > 0:
+			// Split "requestId" before I and "HTTPStatus" before S.
+			prev := runes[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				i+1 < len(runes) && unicode.IsUpper(prev) && unicode.IsLower(runes[i+1]) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
+
+// keyCollision returns an error when two names of one of the maps convert
+// to the same key with KeyCase, as they would overwrite each other.
+func (config *LoggerConfig) keyCollision(maps ...map[string]string) error {
+	if config.keyCase == nil {
+		return nil
+	}
+	for _, m := range maps {
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		seen := make(map[string]string, len(names))
+		for _, name := range names {
+			key := config.keyCase(name)
+			if other, ok := seen[key]; ok {
+				return fmt.Errorf("glog: keys %q and %q are both %q with KeyCase %q", other, name, key, config.KeyCase)
+			}
+			seen[key] = name
+		}
+	}
+	return nil
+}
+
+// casedKey returns name converted per KeyCase.
+func (config *LoggerConfig) casedKey(name string) string {
+	if config.keyCase == nil {
+		return name
+	}
+	return config.keyCase(name)
+}
@@ -0,0 +1,97 @@
+package glog
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestKeyCase(t *testing.T) {
+	fields := map[string]string{
+		"request_id": "id",
+		"bytesOut":   "bytes_out",
+		"HTTPStatus": "status",
+	}
+	static := map[string]string{"service-name": "api"}
+	for keyCase, want := range map[string]map[string]interface{}{
+		"": {
+			"request_id": "abc", "bytesOut": 2.0, "HTTPStatus": 200.0, "service-name": "api",
+		},
+		"snake": {
+			"request_id": "abc", "bytes_out": 2.0, "http_status": 200.0, "service_name": "api",
+		},
+		"camel": {
+			"requestId": "abc", "bytesOut": 2.0, "httpStatus": 200.0, "serviceName": "api",
+		},
+		"pascal": {
+			"RequestId": "abc", "BytesOut": 2.0, "HttpStatus": 200.0, "ServiceName": "api",
+		},
+	} {
+		r, out, _ := newTestRouter(t, LoggerConfig{Fields: fields, StaticFields: static, KeyCase: keyCase})
+		r.GET("/", func(ctx *gin.Context) { ctx.String(http.StatusOK, "ok") })
+		serve(r, "GET", "/", nil, "X-Request-ID", "abc")
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(out.String()), &entry); err != nil {
+			t.Fatalf("%q: entry is not JSON: %v\n%s", keyCase, err, out)
+		}
+		if !reflect.DeepEqual(entry, want) {
+			t.Errorf("%q: entry = %v, want %v", keyCase, entry, want)
+		}
+	}
+
+	if _, err := newMiddleware(LoggerConfig{Fields: fields, KeyCase: "kebab", Output: discard{}}); err == nil {
+		t.Error("invalid key case accepted")
+	}
+}
+
+func TestKeyCaseRunes(t *testing.T) {
+	for keyCase, want := range map[string]string{
+		"camel":  "élanÉtat",
+		"pascal": "ÉlanÉtat",
+		"snake":  "élan_état",
+	} {
+		caser, _ := keyCaser(keyCase)
+		if got := caser("élan_état"); got != want {
+			t.Errorf("%s: %q, want %q", keyCase, got, want)
+		}
+	}
+}
+
+func TestKeyCaseCollision(t *testing.T) {
+	for _, config := range []LoggerConfig{
+		{Fields: map[string]string{"user_id": "id", "userId": "status"}, KeyCase: "camel"},
+		{Fields: map[string]string{"id": "id"}, StaticFields: map[string]string{"app-name": "a", "app_name": "b"}, KeyCase: "snake"},
+	} {
+		config.Output = discard{}
+		if _, err := newMiddleware(config); err == nil {
+			t.Errorf("colliding keys %v %v accepted", config.Fields, config.StaticFields)
+		}
+	}
+	if _, err := newMiddleware(LoggerConfig{Fields: map[string]string{"user_id": "id", "userId": "status"}, Output: discard{}}); err != nil {
+		t.Errorf("distinct keys without KeyCase rejected: %v", err)
+	}
+
+	// Fields win over StaticFields and route info once cased.
+	diag := new(syncBuffer)
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Fields:       map[string]string{"user_id": "param:id"},
+		StaticFields: map[string]string{"userId": "static", "team-name": "static"},
+		RouteInfoProvider: func(method, route string) map[string]string {
+			return map[string]string{"team_name": "a", "teamName": "b"}
+		},
+		KeyCase:           "camel",
+		DiagnosticsOutput: diag,
+	})
+	r.GET("/:id", func(ctx *gin.Context) {})
+	serve(r, "GET", "/7", nil)
+	if want := `{"teamName":"b","userId":"7"}` + "\n"; out.String() != want {
+		t.Errorf("entry = %q, want %q", out, want)
+	}
+	if !strings.Contains(diag.String(), `"teamName" and "team_name"`) && !strings.Contains(diag.String(), `"team_name" and "teamName"`) {
+		t.Errorf("diagnostics = %q, want the route info collision", diag)
+	}
+}
@@ -0,0 +1,39 @@
+package glog
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/zt-tech/glog/color"
+)
+
+// latencyUnits are the suffixes of the units allowed as LatencyUnit.
+var latencyUnits = map[time.Duration]string{
+	time.Nanosecond:  "ns",
+	time.Microsecond: "µs",
+	time.Millisecond: "ms",
+	time.Second:      "s",
+	time.Minute:      "m",
+	time.Hour:        "h",
+}
+
+// latencyText renders d for the latency_human tag: d.String() without
+// LatencyUnit, otherwise d in that unit with LatencyPrecision decimals,
+// e.g. "1500.000ms". With ColorLatency the value is colored by
+// SlowThreshold.
+func (config *LoggerConfig) latencyText(c *color.Color, d time.Duration) string {
+	s := d.String()
+	if config.LatencyUnit > 0 {
+		s = strconv.FormatFloat(float64(d)/float64(config.LatencyUnit), 'f', config.LatencyPrecision, 64) + latencyUnits[config.LatencyUnit]
+	}
+	if !config.ColorLatency || config.SlowThreshold <= 0 {
+		return s
+	}
+	switch {
+	case d >= config.SlowThreshold:
+		return c.Red(s)
+	case d >= config.SlowThreshold/2:
+		return c.Yellow(s)
+	}
+	return c.Green(s)
+}
@@ -0,0 +1,57 @@
+package glog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zt-tech/glog/color"
+)
+
+func TestLatencyHuman(t *testing.T) {
+	for _, tc := range []struct {
+		unit      time.Duration
+		precision int
+		want      string
+	}{
+		{0, 0, "1.5s"},
+		{time.Millisecond, 3, "1500.000ms"},
+		{time.Second, 1, "1.5s"},
+		{time.Microsecond, 0, "1500000µs"},
+	} {
+		r, out, _ := newTestRouter(t, LoggerConfig{
+			Format:           "${latency_human}\n",
+			LatencyUnit:      tc.unit,
+			LatencyPrecision: tc.precision,
+			now:              steppingClock(1500 * time.Millisecond),
+		})
+		r.GET("/", func(ctx *gin.Context) {})
+		serve(r, "GET", "/", nil)
+		if got := strings.TrimSuffix(out.String(), "\n"); got != tc.want {
+			t.Errorf("LatencyUnit %v: latency_human = %q, want %q", tc.unit, got, tc.want)
+		}
+	}
+	if _, err := newMiddleware(LoggerConfig{Output: discard{}, LatencyUnit: 3 * time.Millisecond}); err == nil {
+		t.Error("invalid LatencyUnit accepted")
+	}
+}
+
+func TestColorLatency(t *testing.T) {
+	config := &LoggerConfig{ColorLatency: true, SlowThreshold: time.Second}
+	c := color.New()
+	c.Enable()
+	for d, want := range map[time.Duration]string{
+		100 * time.Millisecond: c.Green("100ms"),
+		600 * time.Millisecond: c.Yellow("600ms"),
+		time.Second:            c.Red("1s"),
+	} {
+		if got := config.latencyText(c, d); got != want {
+			t.Errorf("latencyText(%v) = %q, want %q", d, got, want)
+		}
+	}
+	c.Disable()
+	if got := config.latencyText(c, time.Second); got != "1s" {
+		t.Errorf("latencyText without colors = %q", got)
+	}
+}
@@ -0,0 +1,138 @@
+package glog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Level is the severity of an entry, rendered by the level_value tag.
+// Levels are configured and rendered by the level tag as their String.
+type Level int
+
+// The levels from the least to the most severe.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the name of l: "debug", "info", "warn" or "error".
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "info"
+}
+
+// ParseLevel returns the Level named name: "debug", "info", "warn" or
+// "error".
+func ParseLevel(name string) (Level, error) {
+	switch name {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("glog: unknown level %q", name)
+}
+
+// MarshalText returns the name of l, so Level keys and values are encoded
+// as names in JSON and YAML.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText parses a name accepted by ParseLevel.
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// maxLevel returns the more severe of a and b.
+func maxLevel(a, b Level) Level {
+	if b > a {
+		return b
+	}
+	return a
+}
+
+// entryLevel derives the level of the entry of a request, see level.
+// AbortedLevel replaces it for aborted requests below LevelError, so an
+// abort never hides a 5xx or a recorded error. An error of a request the
+// client canceled is downgraded to LevelWarn: the handler usually failed
+// because the client went away. Slow requests are at least LevelWarn.
+func (config *LoggerConfig) entryLevel(ctx *gin.Context, aborted, slow bool) Level {
+	level := config.level(ctx)
+	if aborted && config.AbortedLevel != "" && level < LevelError {
+		level = config.abortedLevel
+	}
+	if level == LevelError && ctx.Request.Context().Err() == context.Canceled {
+		level = LevelWarn
+	}
+	if slow {
+		level = maxLevel(level, LevelWarn)
+	}
+	return level
+}
+
+// level derives the level of the request from its status, ContextError and
+// the errors recorded with ctx.Error: 5xx and gin errors are LevelError,
+// 4xx is LevelWarn and ContextError maps to TreatContextErrorAs.
+func (config *LoggerConfig) level(ctx *gin.Context) Level {
+	level := LevelInfo
+	switch status := ctx.Writer.Status(); {
+	case status >= 500:
+		level = LevelError
+	case status >= 400:
+		level = LevelWarn
+	}
+	if _, ok := ctx.Get(ContextError); ok {
+		level = maxLevel(level, config.treatContextErrorAs)
+	}
+	if len(ctx.Errors) > 0 {
+		level = LevelError
+	}
+	return level
+}
+
+// errorText joins the ContextError value and the errors recorded with
+// ctx.Error. It is empty when there is no error.
+func errorText(ctx *gin.Context) string {
+	var msgs []string
+	if v, ok := ctx.Get(ContextError); ok && v != nil {
+		switch e := v.(type) {
+		case string:
+			msgs = append(msgs, e)
+		case error:
+			msgs = append(msgs, e.Error())
+		default:
+			if b, err := json.Marshal(e); err == nil {
+				msgs = append(msgs, string(b))
+			} else {
+				msgs = append(msgs, fmt.Sprint(e))
+			}
+		}
+	}
+	for _, e := range ctx.Errors {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,200 @@
+package glog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseLevel(t *testing.T) {
+	for name, want := range map[string]Level{
+		"debug": LevelDebug,
+		"info":  LevelInfo,
+		"warn":  LevelWarn,
+		"error": LevelError,
+	} {
+		l, err := ParseLevel(name)
+		if err != nil || l != want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", name, l, err, want)
+		}
+		if l.String() != name {
+			t.Errorf("%v.String() = %q, want %q", l, l.String(), name)
+		}
+	}
+	for _, name := range []string{"", "warning", "ERROR", "fatal"} {
+		if _, err := ParseLevel(name); err == nil {
+			t.Errorf("ParseLevel(%q) succeeded, want error", name)
+		}
+	}
+}
+
+func TestLevelValidation(t *testing.T) {
+	for _, config := range []LoggerConfig{
+		{MinLevel: "warning"},
+		{TreatContextErrorAs: "fatal"},
+	} {
+		config.Output = new(bytes.Buffer)
+		if _, err := newMiddleware(config); err == nil {
+			t.Errorf("newMiddleware(%+v) succeeded, want error", config)
+		}
+	}
+	if _, err := newMiddleware(LoggerConfig{Output: new(bytes.Buffer), MinLevel: "warn"}); err != nil {
+		t.Errorf("newMiddleware with MinLevel warn: %v", err)
+	}
+}
+
+func TestAbortedLevel(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${path} ${level}\n", AbortedLevel: "info"})
+	auth := func(ctx *gin.Context) {
+		if ctx.Query("abort") != "" {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+		}
+	}
+	r.GET("/401", auth, func(ctx *gin.Context) { ctx.Status(http.StatusUnauthorized) })
+	r.GET("/500", func(ctx *gin.Context) { ctx.AbortWithStatus(http.StatusInternalServerError) })
+	r.GET("/err", func(ctx *gin.Context) {
+		ctx.Error(errors.New("boom"))
+		ctx.AbortWithStatus(http.StatusBadRequest)
+	})
+	for _, target := range []string{"/401?abort=1", "/401", "/500", "/err"} {
+		serve(r, "GET", target, nil)
+	}
+	want := []string{"/401 info", "/401 warn", "/500 error", "/err error"}
+	if got := out.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+	if _, err := newMiddleware(LoggerConfig{Output: new(bytes.Buffer), AbortedLevel: "quiet"}); err == nil {
+		t.Error("unknown AbortedLevel accepted")
+	}
+}
+
+func TestTreatContextErrorAs(t *testing.T) {
+	for _, tc := range []struct {
+		treatAs string
+		want    []string
+	}{
+		{"", []string{"/ok error", "/fail error"}},
+		{"warn", []string{"/ok warn", "/fail error"}},
+		{"info", []string{"/ok info", "/fail error"}},
+	} {
+		r, out, _ := newTestRouter(t, LoggerConfig{Format: "${path} ${level}\n", TreatContextErrorAs: tc.treatAs})
+		r.GET("/ok", func(ctx *gin.Context) { ctx.Set(ContextError, "cache miss") })
+		r.GET("/fail", func(ctx *gin.Context) {
+			ctx.Set(ContextError, "cache miss")
+			ctx.Status(http.StatusInternalServerError)
+		})
+		serve(r, "GET", "/ok", nil)
+		serve(r, "GET", "/fail", nil)
+		if got := out.Lines(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("TreatContextErrorAs %q: entries = %q, want %q", tc.treatAs, got, tc.want)
+		}
+	}
+}
+
+func TestSeverityNumber(t *testing.T) {
+	for _, tc := range []struct {
+		numbers map[Level]int
+		want    []string
+	}{
+		{nil, []string{"info 6 1", "warn 4 2", "error 3 3"}},
+		{map[Level]int{LevelInfo: 200, LevelError: 500}, []string{"info 200 1", "warn 0 2", "error 500 3"}},
+	} {
+		r, out, _ := newTestRouter(t, LoggerConfig{Format: "${level} ${severity_number} ${level_value}\n", SeverityNumbers: tc.numbers})
+		r.GET("/:code", func(ctx *gin.Context) {
+			switch ctx.Param("code") {
+			case "404":
+				ctx.Status(http.StatusNotFound)
+			case "500":
+				ctx.Status(http.StatusInternalServerError)
+			}
+		})
+		for _, code := range []string{"200", "404", "500"} {
+			serve(r, "GET", "/"+code, nil)
+		}
+		if got := out.Lines(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("SeverityNumbers %v: entries = %q, want %q", tc.numbers, got, tc.want)
+		}
+	}
+}
+
+func TestEntryLevel(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	for _, tc := range []struct {
+		name    string
+		config  LoggerConfig
+		status  int
+		ctxErr  bool
+		ginErr  bool
+		aborted bool
+		slow    bool
+		reqCtx  context.Context
+		want    Level
+	}{
+		{name: "200", status: 200, want: LevelInfo},
+		{name: "404", status: 404, want: LevelWarn},
+		{name: "503", status: 503, want: LevelError},
+		{name: "context error", status: 200, ctxErr: true, want: LevelError},
+		{name: "context error as warn", config: LoggerConfig{TreatContextErrorAs: "warn"}, status: 200, ctxErr: true, want: LevelWarn},
+		{name: "context error on 5xx", config: LoggerConfig{TreatContextErrorAs: "info"}, status: 500, ctxErr: true, want: LevelError},
+		{name: "gin error", status: 200, ginErr: true, want: LevelError},
+		{name: "slow", status: 200, slow: true, want: LevelWarn},
+		{name: "slow 5xx", status: 500, slow: true, want: LevelError},
+		{name: "aborted", config: LoggerConfig{AbortedLevel: "info"}, status: 401, aborted: true, want: LevelInfo},
+		{name: "aborted 5xx", config: LoggerConfig{AbortedLevel: "info"}, status: 500, aborted: true, want: LevelError},
+		{name: "aborted slow", config: LoggerConfig{AbortedLevel: "info"}, status: 401, aborted: true, slow: true, want: LevelWarn},
+		{name: "client canceled", status: 500, ginErr: true, reqCtx: canceled, want: LevelWarn},
+		{name: "client canceled 200", status: 200, reqCtx: canceled, want: LevelInfo},
+		{name: "deadline exceeded", status: 503, reqCtx: expired, want: LevelError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.Output = discard{}
+			m, err := newMiddleware(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest("GET", "/", nil)
+			if tc.reqCtx != nil {
+				ctx.Request = ctx.Request.WithContext(tc.reqCtx)
+			}
+			ctx.Status(tc.status)
+			if tc.ctxErr {
+				ctx.Set(ContextError, "failed")
+			}
+			if tc.ginErr {
+				ctx.Error(errors.New("failed"))
+			}
+			if got := m.config.entryLevel(ctx, tc.aborted, tc.slow); got != tc.want {
+				t.Errorf("level = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLevelText(t *testing.T) {
+	var numbers map[Level]int
+	if err := json.Unmarshal([]byte(`{"warn":4,"error":3}`), &numbers); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[Level]int{LevelWarn: 4, LevelError: 3}; !reflect.DeepEqual(numbers, want) {
+		t.Errorf("SeverityNumbers = %v, want %v", numbers, want)
+	}
+	if b, _ := json.Marshal(map[string]Level{"level": LevelWarn}); string(b) != `{"level":"warn"}` {
+		t.Errorf("encoded %s, want the level name", b)
+	}
+	var l Level
+	if err := l.UnmarshalText([]byte("fatal")); err == nil {
+		t.Error("unknown level accepted")
+	}
+}
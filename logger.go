@@ -2,14 +2,22 @@ package glog
 
 import (
 	"bytes"
+	crand "crypto/rand"
+	"crypto/rsa"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
+	"net/http"
 	"os"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,12 +30,41 @@ const (
 	ContextError = "context_error"
 	// ContextAppID appID
 	ContextAppID = "context_app_id"
+	// ContextRequestID request ID
+	ContextRequestID = "context_request_id"
+	// ContextCaptureBody set by CaptureBody
+	ContextCaptureBody = "context_capture_body"
+	// ContextCaptureResponse set by CaptureResponse
+	ContextCaptureResponse = "context_capture_response"
+	// ContextEntry rendered entry, set with StoreEntry
+	ContextEntry = "context_entry"
+	// ContextRequestBody captured request body ([]byte), set before the
+	// handlers run when the body tag buffered it completely
+	ContextRequestBody = "context_request_body"
+	// ContextResponseBody captured response body ([]byte), set after the
+	// handlers with ExposeResponseBody
+	ContextResponseBody = "context_response_body"
 )
 
 type (
 	// LoggerConfig defines the config for Logger middleware.
 	LoggerConfig struct {
+		// Skip lists the paths that are not logged. Paths ending in "*"
+		// match by prefix ("/static/*") and paths with other glob
+		// characters use path.Match ("/users/*/avatar").
+		// Optional. Default value nil.
 		Skip map[string]struct{}
+
+		// SkipPaths lists more paths that are not logged, with the same
+		// matching rules as Skip.
+		// Optional. Default value nil.
+		SkipPaths []string `yaml:"skip_paths"`
+
+		// Skipper returns true for requests that are passed through without
+		// being captured or logged. It runs before any body is buffered.
+		// Optional. Default value nil.
+		Skipper func(*gin.Context) bool
+
 		// Tags to construct the logger format.
 		//
 		// - time_unix
@@ -35,26 +72,81 @@ type (
 		// - time_rfc3339
 		// - time_rfc3339_nano
 		// - time_custom
+		// - id (RequestIDHeader header or, with GenerateRequestID, a
+		//   generated UUID)
 		// - remote_ip
 		// - uri
 		// - host
-		// - method
+		// - method (X-HTTP-Method-Override when TrustMethodOverride is set)
+		// - raw_method
 		// - path
-		// - query
+		// - query (Raw query, sensitive parameters masked)
+		// - query_decoded (URL-decoded query, sensitive parameters masked)
+		// - params_object (Route parameters as a JSON object)
+		// - transfer_encoding (How the response body was framed: chunked,
+		//   content-length or none, see transferEncoding)
+		// - middleware_timings (Durations recorded with RecordMiddleware,
+		//   as a JSON object of nanoseconds)
+		// - request_headers, response_headers (All headers as a JSON
+		//   object, masked per MaskedHeaders, see ExcludedHeaders)
 		// - protocol
 		// - referer
+		// - origin (Origin request header)
+		// - cors_allowed (true when Access-Control-Allow-Origin of the
+		//   response is "*" or the request Origin)
+		// - request_fingerprint (Heuristic hash, see FingerprintFields)
+		// - cookie_count
+		// - cookies (Cookie names only, values are never logged)
 		// - user_agent
-		// - status
-		// - level
-		// - error
+		// - status (What the client receives: 200 when the handlers wrote
+		//   nothing, gin writes it after the chain)
+		// - status_explicit (false when the handlers neither wrote a
+		//   response nor set a status other than 200)
+		// - level (info, warn for 4xx, error for 5xx and errors, warn
+		//   instead of error when the client canceled the request)
+		// - level_value (Level as a number: 1 info, 2 warn, 3 error)
+		// - severity_number (Level as a number, see SeverityNumbers)
+		// - slow (true when the latency reached SlowThreshold)
+		// - trace_id, span_id (See TraceContext)
+		// - stack (Stack trace of a recovered panic, see Recover)
+		// - matched (false for requests matching no route, e.g. 404)
+		// - aborted (true when the chain was aborted, e.g. by an auth
+		//   middleware)
+		// - error (ContextError and ctx.Errors, empty when none)
 		// - app_id
+		// - seq (Per-logger sequence number)
+		// - sample_rate (Probability the entry was sampled with)
+		// - prev_hash (Hash of the previous entry, see TamperEvident)
+		// - open_fds (Open file descriptors on error lines, see LogOpenFDs)
+		// - heap_alloc (Heap bytes allocated on error lines, see LogMemStats)
+		// - alloc_bytes (Bytes allocated during the request, see LogAllocs)
+		// - bytes_in (Request body size)
+		// - bytes_out (Response body size, as written by the handlers)
+		// - bytes_sent (Part of bytes_out the connection accepted, less
+		//   when the client went away during the response)
+		// - write_error (First error writing the response, e.g. a broken
+		//   pipe; empty when none)
 		// - latency (In nanoseconds)
 		// - latency_human (Human readable)
+		// - upstream_latency (In nanoseconds, see UpstreamTransport and
+		//   ContextUpstreamLatency; -1 when not recorded)
+		// - latency_console, remote_ip_console (Padded for alignment)
+		// - method_color (Colored per verb and padded, see ConsoleFormat)
+		// - extras (" | level=... error=... app_id=..." with the non-default
+		//   values, see ConsoleFormat)
 		// - body
 		// - response
+		// - response_head (First ResponseHeadSize bytes of the response)
 		// - header:<NAME>
+		// - header_out:<NAME> (Response header, masked like header:<NAME>)
 		// - query:<NAME>
 		// - form:<NAME>
+		// - param:<NAME> (Route parameter)
+		// - context:<KEY>
+		// - route_info:<KEY> (See RouteInfoProvider)
+		// - field:<NAME> (See StaticFields)
+		// - env:<NAME> (Environment variable, read once)
+		// - any name registered in CustomTags
 
 		//
 		// Example "${remote_ip} ${status}"
@@ -62,21 +154,663 @@ type (
 		// Optional. Default value DefaultLoggerConfig.Format.
 		Format string `yaml:"format"`
 
+		// EscapeJSON escapes the values of the string tags of Format and
+		// ShadowFormat for a JSON string: quotes, backslashes, control
+		// characters and invalid UTF-8. Numeric and JSON valued tags such as
+		// status, latency, bytes_in or params_object are written as is.
+		// Use it when the format is JSON and the tags are quoted, as in
+		// DefaultLoggerConfig.Format. It is always on when Format is
+		// empty or starts with "{". Fields is always valid JSON.
+		// Optional. Default value false, true in DefaultLoggerConfig.
+		EscapeJSON bool `yaml:"escape_json"`
+
+		// ShadowFormat is rendered for every logged request in addition to
+		// Format/Fields and written to ShadowOutput, e.g. to validate a new
+		// schema before switching to it. Shadow failures never affect the
+		// primary output, see Stats.ShadowFailures.
+		// Optional. Default value "" (disabled).
+		ShadowFormat string `yaml:"shadow_format"`
+
+		// ShadowOutput receives the ShadowFormat entries.
+		// Optional. Default value nil (ShadowFormat disabled).
+		ShadowOutput io.Writer
+
+		// Fields maps JSON field names to tag names, e.g.
+		// {"status": "status", "ua": "user_agent"}. When set it replaces
+		// Format: every entry is encoded with encoding/json so values are
+		// always escaped and numeric tags stay numbers.
+		// Optional. Default value nil (use Format).
+		Fields map[string]string `yaml:"fields"`
+
+		// Structured encodes entries like Fields with the standard field
+		// set DefaultStructuredFields when Fields is not set.
+		// Optional. Default value false.
+		Structured bool `yaml:"structured"`
+
 		// Optional. Default value DefaultLoggerConfig.CustomTimeFormat.
 		CustomTimeFormat string `yaml:"custom_time_format"`
 
+		// CustomTags maps extra tag names to functions computing their value,
+		// e.g. {"tenant": func(ctx *gin.Context) string { ... }} for
+		// ${tenant}. Built-in tags and tag families take precedence. The
+		// functions run after ctx.Next(), so they can read values set by
+		// the handlers and the response state such as ctx.Writer.Status().
+		// Optional. Default value nil.
+		CustomTags map[string]func(*gin.Context) string
+
+		// TimeAtStart makes the time tags render the instant the request
+		// started instead of the instant it completed. All time tags of an
+		// entry always render the same instant.
+		// Optional. Default value false (completion time).
+		TimeAtStart bool `yaml:"time_at_start"`
+
+		// TrustMethodOverride makes the method tag log the method from the
+		// X-HTTP-Method-Override header, raw_method keeps the real one.
+		// Optional. Default value false.
+		TrustMethodOverride bool `yaml:"trust_method_override"`
+
+		// FingerprintFields lists the components hashed by the
+		// request_fingerprint tag: "method", "route" (path template),
+		// "query_names" (sorted parameter names), "ua_family" and
+		// "ip_prefix" (client IP masked to /24 or /48).
+		// Optional. Default value DefaultLoggerConfig.FingerprintFields.
+		FingerprintFields []string `yaml:"fingerprint_fields"`
+
+		// RequestIDHeader is the header the request ID is read from and
+		// written back to on the response.
+		// Optional. Default value DefaultLoggerConfig.RequestIDHeader.
+		RequestIDHeader string `yaml:"request_id_header"`
+
+		// LoopProtection keeps a sink pointed at a server using this
+		// middleware from creating a feedback loop. Requests sent through
+		// Middleware.SinkTransport carry SinkHeader set to SinkSecret and
+		// are not logged. A writer dispatching requests into the server in
+		// process must mark them with SinkContext, so they cannot recurse.
+		// Optional. Default value false.
+		LoopProtection bool `yaml:"loop_protection"`
+
+		// SinkHeader is the header marking the requests of SinkTransport,
+		// see LoopProtection.
+		// Optional. Default value DefaultLoggerConfig.SinkHeader.
+		SinkHeader string `yaml:"sink_header"`
+
+		// SinkSecret is the SinkHeader value of the requests of
+		// SinkTransport. Set the same secret on every replica when the
+		// sink requests of one replica may reach another.
+		// Optional. Default value a random secret of the process.
+		SinkSecret string `yaml:"sink_secret"`
+
+		// GenerateRequestID generates a UUID v4 request ID when the request
+		// has no RequestIDHeader. The ID is stored in the context under
+		// ContextRequestID and set on the response header. It is always on
+		// when Format is empty, the default format logs the id.
+		// Optional. Default value false, true in DefaultLoggerConfig.
+		GenerateRequestID bool `yaml:"generate_request_id"`
+
+		// SensitiveFields lists the JSON keys and query parameters whose
+		// values are masked with "***" in the body, response and query
+		// tags. Names match case-insensitively and exactly, JSON keys at
+		// any depth.
+		// Optional. Default value DefaultLoggerConfig.SensitiveFields.
+		SensitiveFields []string `yaml:"sensitive_fields"`
+
+		// MaskedHeaders lists the headers, matched case-insensitively,
+		// whose values are logged as "***" by the header tags. Authorization
+		// and Proxy-Authorization keep the scheme of values like "Bearer
+		// <token>". Set it to an empty slice to log every header verbatim.
+		// Optional. Default value DefaultLoggerConfig.MaskedHeaders.
+		MaskedHeaders []string `yaml:"masked_headers"`
+
+		// ExcludedHeaders lists the headers, matched case-insensitively,
+		// left out of the request_headers and response_headers tags, e.g.
+		// noisy ones like Accept and Accept-Encoding.
+		// Optional. Default value nil.
+		ExcludedHeaders []string `yaml:"excluded_headers"`
+
+		// MaskedCookies lists the cookies masked by the cookie tag.
+		// Optional. Default value nil.
+		MaskedCookies []string `yaml:"masked_cookies"`
+
+		// SampleRateByStatus maps a status class (2 for 2xx, 5 for 5xx...)
+		// to the fraction of requests in [0, 1] that are logged. Classes
+		// not present are always logged.
+		// Optional. Default value nil (log everything).
+		SampleRateByStatus map[int]float64 `yaml:"sample_rate_by_status"`
+
+		// SampleRate is the fraction of requests in (0, 1] that are logged
+		// for the status classes not present in SampleRateByStatus. Entries
+		// at level "error" and slow requests (see SlowThreshold) are always
+		// logged. Sampling applies after MinStatus and MinLevel: an entry
+		// they drop is never logged, whatever its rate.
+		// Optional. Default value 0 (log everything).
+		SampleRate float64 `yaml:"sample_rate"`
+
+		// Sampler decides whether a request is logged once the handlers
+		// returned, e.g. to keep every error and slow request and a
+		// fraction of the rest. It replaces SampleRate, SampleRateByStatus
+		// and TargetRate; NeverSample routes are still always logged and
+		// sample_rate renders 1. Dropped requests are not rendered.
+		// Optional. Default value nil.
+		Sampler func(ctx *gin.Context, status int, latency time.Duration) bool `yaml:"-"`
+
+		// TamperEvident chains every entry to the SHA-256 of the previous
+		// one, exposed by the prev_hash tag. Entries are rendered and
+		// written one at a time while it is enabled.
+		// Optional. Default value false.
+		TamperEvident bool `yaml:"tamper_evident"`
+
+		// LogOpenFDs enables the open_fds tag on error lines. Counting the
+		// descriptors is expensive and only supported on Linux; the tag
+		// renders -1 when disabled, on other lines or other platforms.
+		// Optional. Default value false.
+		LogOpenFDs bool `yaml:"log_open_fds"`
+
+		// MaxBodySize caps the number of bytes kept for the body and
+		// response tags, longer values end with
+		// "...(truncated, <N> bytes total)". A request body whose
+		// Content-Length exceeds it is not buffered at all, chunked bodies
+		// are captured while the handler reads them. The handler and the
+		// client always see the full data.
+		// Optional. Default value 0 (unlimited).
+		MaxBodySize int `yaml:"max_body_size"`
+
+		// MaxBodyLogSize overrides MaxBodySize for the body tag.
+		// Optional. Default value 0 (use MaxBodySize).
+		MaxBodyLogSize int `yaml:"max_body_log_size"`
+
+		// MaxResponseLogSize overrides MaxBodySize for the response tag.
+		// Optional. Default value 0 (use MaxBodySize).
+		MaxResponseLogSize int `yaml:"max_response_log_size"`
+
+		// ResponseHeadSize is the number of bytes kept for the
+		// response_head tag. When the response tag is not used only that
+		// many bytes of the response are ever buffered.
+		// Optional. Default value DefaultLoggerConfig.ResponseHeadSize.
+		ResponseHeadSize int `yaml:"response_head_size"`
+
+		// CompactBody collapses runs of whitespace in bodies and responses
+		// that are not valid JSON. Without it only line breaks and the
+		// indentation following them are removed. JSON is always
+		// re-encoded compactly.
+		// Optional. Default value false.
+		CompactBody bool `yaml:"compact_body"`
+
+		// LogMultipartBody enables the body tag for multipart requests,
+		// which are usually file uploads.
+		// Optional. Default value false.
+		LogMultipartBody bool `yaml:"log_multipart_body"`
+
+		// BodyContentTypes restricts the body, response and response_head
+		// tags to the listed content types, e.g. "application/json" or
+		// "text/*". Other bodies are not buffered and log
+		// "[omitted: <content type>]" instead.
+		// Optional. Default value nil (every content type is logged).
+		BodyContentTypes []string `yaml:"body_content_types"`
+
+		// LogMemStats enables the heap_alloc tag on error lines. Reading the
+		// memory stats stops the world briefly; the tag renders -1 when
+		// disabled or on other lines.
+		// Optional. Default value false.
+		LogMemStats bool `yaml:"log_mem_stats"`
+
+		// TreatContextErrorAs is the level used when ContextError is set,
+		// e.g. "warn" for handlers reporting non-fatal problems. Unknown
+		// level names are rejected.
+		// Optional. Default value DefaultLoggerConfig.TreatContextErrorAs.
+		TreatContextErrorAs string `yaml:"treat_context_error_as"`
+
+		// Hook is called with every handled request once the handlers
+		// returned, including the requests dropped by MinLevel, MinStatus,
+		// sampling or ClientBudget, e.g. to update metrics. Skip, SkipPaths
+		// and Skipper requests are reported with LogEntry.Skipped set,
+		// requests from SinkTransport are not. It runs synchronously on the
+		// request goroutine, so it must be fast and safe for concurrent
+		// use; a panic in Hook is recovered and reported once to
+		// DiagnosticsOutput.
+		// Optional. Default value nil.
+		Hook func(entry LogEntry) `yaml:"-"`
+
+		// DryRun writes every entry, including those for Outputs, ErrorOutput,
+		// ShadowOutput and AbortedOutput, to an internal buffer keeping the
+		// last DryRunSize lines instead of the configured writers, e.g. to
+		// try a format or the redaction in tests. See
+		// Middleware.DryRunLines.
+		// Optional. Default value false.
+		DryRun bool `yaml:"dry_run"`
+
+		// DryRunSize is the number of lines kept in DryRun mode.
+		// Optional. Default value DefaultLoggerConfig.DryRunSize.
+		DryRunSize int `yaml:"dry_run_size"`
+
+		// ExposeResponseBody stores the captured response under
+		// ContextResponseBody once the handlers returned, for middleware
+		// registered before the logger and Hook. It is only set when the
+		// response tag captured the whole body, see MaxResponseLogSize and
+		// BodyContentTypes. The slice must not be modified.
+		// Optional. Default value false.
+		ExposeResponseBody bool `yaml:"expose_response_body"`
+
+		// CollectStats keeps per-route request and error counts and
+		// latency quantiles in memory, see Middleware.Snapshot. Like Hook
+		// it covers every request except Skip and Skipper ones.
+		// Optional. Default value false.
+		CollectStats bool `yaml:"collect_stats"`
+
+		// KeyCase converts the keys of the Fields output, including the
+		// pairs merged from RouteInfoProvider and StaticFields: "snake"
+		// (request_id), "camel" (requestId) or "pascal" (RequestId). Empty
+		// keeps the keys as configured. Fields, or StaticFields, whose
+		// names convert to the same key are rejected.
+		// Optional. Default value "".
+		KeyCase string `yaml:"key_case"`
+
+		// StaticFields are constant values, e.g. service, env, region and
+		// version, available as ${field:<NAME>} and added to the Fields
+		// output unless a field of the same name exists. A value of the
+		// form "${env:<NAME>}" is read from the environment variable NAME.
+		// Environment variables, here and for the env:<NAME> tags, are
+		// read once when the middleware is built.
+		// Optional. Default value nil.
+		StaticFields map[string]string `yaml:"static_fields"`
+
+		// RouteInfoProvider returns metadata of a route, e.g. the owner team
+		// and SLO class from a central registry, given the method and the
+		// route template. It is called once per method and route, the
+		// result is memoized. The pairs are available as
+		// ${route_info:<KEY>} and added to the Fields output unless a field
+		// of the same name exists. Requests matching no route get nothing;
+		// panics are recovered and counted in Stats.RouteInfoFailures.
+		// Optional. Default value nil.
+		RouteInfoProvider func(method, route string) map[string]string `yaml:"-"`
+
+		// TraceContext returns the trace and span IDs of the request for the
+		// trace_id and span_id tags, e.g. from an OpenTelemetry span set
+		// by an earlier middleware. The otelglog module provides it:
+		//
+		//	config.TraceContext = otelglog.TraceContext
+		//
+		// When it is nil or returns an empty trace ID, the W3C traceparent
+		// header is used, then X-B3-TraceId and X-B3-SpanId.
+		// Optional. Default value nil.
+		TraceContext func(r *http.Request) (traceID, spanID string) `yaml:"-"`
+
+		// FastPath renders entries of the default Format without an error
+		// with a dedicated encoder instead of the template. The output is
+		// byte for byte the same; other formats ignore it.
+		// Optional. Default value false.
+		FastPath bool `yaml:"fast_path"`
+
+		// StoreEntry stores the rendered entry in the context under
+		// ContextEntry once it is written, so middleware registered before
+		// the logger can reuse it after ctx.Next returns, see Entry.
+		// Optional. Default value false.
+		StoreEntry bool `yaml:"store_entry"`
+
+		// SeverityNumbers maps the levels to the numbers rendered by the
+		// severity_number tag, e.g. for GCP severities. Levels missing
+		// from the map render 0. In YAML and JSON the keys are level
+		// names.
+		// Optional. Default value DefaultLoggerConfig.SeverityNumbers
+		// (syslog: info 6, warn 4, error 3).
+		SeverityNumbers map[Level]int `yaml:"severity_numbers"`
+
+		// Recover catches panics of the handlers: the error tag reports
+		// "panic: <value>", the stack tag the stack trace, the level is
+		// "error" and the client gets a 500. The entry keeps the latency
+		// and captured bodies of the request. As with gin.Recovery, a
+		// broken pipe (the client went away) is logged without stack,
+		// response or repanic.
+		// Optional. Default value false.
+		Recover bool `yaml:"recover"`
+
+		// RepanicAfterLog raises a recovered panic again once the entry is
+		// written instead of aborting with 500, for a gin.Recovery or
+		// similar middleware registered before this one.
+		// http.ErrAbortHandler is always raised again, a broken pipe never.
+		// Optional. Default value false.
+		RepanicAfterLog bool `yaml:"repanic_after_log"`
+
+		// CapturePerRoute renders the body and response tags only for routes
+		// registered with CaptureBody and CaptureResponse. Bodies are still
+		// captured for every request since the route is only known after
+		// the handlers ran.
+		// Optional. Default value false.
+		CapturePerRoute bool `yaml:"capture_per_route"`
+
+		// RouteCaptureOverrides overrides the size limits and redaction of
+		// the body, response and response_head tags for the routes it
+		// lists, keyed by gin's FullPath, e.g. to log the raw payload of a
+		// webhook route. Overrides never enable a tag that is not used.
+		// Optional. Default value nil.
+		RouteCaptureOverrides map[string]CaptureOptions `yaml:"route_capture_overrides"`
+
+		// NeverSample lists routes, matched on gin's FullPath, that are
+		// always logged regardless of sampling.
+		// Optional. Default value nil.
+		NeverSample []string `yaml:"never_sample"`
+
+		// TargetRate is the number of entries per second to aim for. The
+		// sample probability is adjusted every second from the observed
+		// traffic; error entries always pass. See Middleware.Stats.
+		// Optional. Default value 0 (disabled).
+		TargetRate float64 `yaml:"target_rate"`
+
+		// DisableColor writes the status tag without ANSI colors even when
+		// the output is a terminal. Colors are off for other writers unless
+		// ForceColor is set.
+		// Optional. Default value false.
+		DisableColor bool `yaml:"disable_color"`
+
+		// ForceColor colors the status tag even when the output is not a
+		// terminal. DisableColor takes precedence.
+		// Optional. Default value false.
+		ForceColor bool `yaml:"force_color"`
+
+		// LogStartupConfig writes one line describing the active format,
+		// output, sampling and redaction settings when the middleware is
+		// built.
+		// Optional. Default value false.
+		LogStartupConfig bool `yaml:"log_startup_config"`
+
+		// LogStartupMarker writes an entry with event "logger_start" when
+		// the middleware is built and "logger_stop" from Close, stating the
+		// format hash, encoder, sampling rate, number of sensitive fields,
+		// output types and build version. Keys are never included.
+		// Optional. Default value false.
+		LogStartupMarker bool `yaml:"log_startup_marker"`
+
+		// MinStatus drops entries whose status is below it, e.g. 400 to log
+		// only failures. Requests slower than SlowThreshold are kept.
+		// Optional. Default value 0 (log every status).
+		MinStatus int `yaml:"min_status"`
+
+		// SlowThreshold marks requests taking at least this long as slow:
+		// their level is at least "warn", the slow tag renders true and
+		// they are kept even when MinStatus or MinLevel would drop them.
+		// Optional. Default value 0 (disabled).
+		SlowThreshold time.Duration `yaml:"slow_threshold"`
+
+		// LatencyUnit renders latency_human as a number of this unit, one
+		// of time.Nanosecond, Microsecond, Millisecond, Second, Minute or
+		// Hour, with LatencyPrecision decimals, e.g. "1500.000ms" for
+		// time.Millisecond and 3. The default is time.Duration.String().
+		// Optional. Default value 0.
+		LatencyUnit time.Duration `yaml:"latency_unit"`
+
+		// LatencyPrecision is the number of decimals of latency_human with
+		// LatencyUnit.
+		// Optional. Default value 0.
+		LatencyPrecision int `yaml:"latency_precision"`
+
+		// ColorLatency colors latency_human like the status: red from
+		// SlowThreshold, yellow from half of it, green below. It needs
+		// SlowThreshold and follows DisableColor and ForceColor.
+		// Optional. Default value false.
+		ColorLatency bool `yaml:"color_latency"`
+
+		// LogAllocs enables the alloc_bytes tag, the growth of
+		// runtime.MemStats.TotalAlloc while the handlers ran. The counter is
+		// process wide so concurrent requests inflate each other's value;
+		// it is only meaningful with little concurrency, e.g. in staging.
+		// Reading the stats twice per request stops the world briefly.
+		// Optional. Default value false.
+		LogAllocs bool `yaml:"log_allocs"`
+
+		// DependencyThreshold is the duration above which a dependency call
+		// recorded with RecordDependency gets its own warning line,
+		// independent of the access line. Like the other lines that are not
+		// entries (budget summaries, startup, marker and key lines) it is
+		// a JSON object when Fields is set or Format starts with "{" and
+		// key=value pairs otherwise, follows KeyCase, MinLevel and
+		// TamperEvident and goes to all Outputs.
+		// Optional. Default value 0 (disabled).
+		DependencyThreshold time.Duration `yaml:"dependency_threshold"`
+
+		// DependencyThresholds overrides DependencyThreshold per dependency
+		// name.
+		// Optional. Default value nil.
+		DependencyThresholds map[string]time.Duration `yaml:"dependency_thresholds"`
+
 		// Output is a writer where logs in JSON format are written.
 		// Optional. Default value os.Stdout.
 		Output io.Writer
 
-		template *fasttemplate.Template
-		colorer  *color.Color
-		pool     *sync.Pool
+		// ClientBudget caps the entries logged per client to this many per
+		// ClientBudgetInterval. Further entries of the client are dropped
+		// and a line "client <key> suppressed <n> entries" is written at
+		// the end of the interval, when the client is evicted and on
+		// Middleware.Close. Clients are tracked in an LRU of
+		// ClientBudgetSize entries.
+		// Optional. Default value 0 (disabled).
+		ClientBudget int `yaml:"client_budget"`
+
+		// ClientBudgetInterval is the period of ClientBudget.
+		// Optional. Default value DefaultLoggerConfig.ClientBudgetInterval.
+		ClientBudgetInterval time.Duration `yaml:"client_budget_interval"`
+
+		// ClientBudgetKey identifies the client: "remote_ip",
+		// "header:<NAME>", e.g. "header:X-API-Key", or "context:<KEY>",
+		// e.g. the user set by an auth middleware. Requests without the
+		// header or context value are keyed by "remote_ip:<IP>".
+		// Optional. Default value "remote_ip".
+		ClientBudgetKey string `yaml:"client_budget_key"`
+
+		// ClientBudgetSize is the number of clients tracked by ClientBudget.
+		// Optional. Default value DefaultLoggerConfig.ClientBudgetSize.
+		ClientBudgetSize int `yaml:"client_budget_size"`
+
+		// BodySink receives the complete request and response bodies keyed
+		// by request ID, independently of MaxBodySize and of the tags used.
+		// A request ID is generated for every request missing one, or
+		// whose ID is not 1 to 128 letters, digits, '-' or '_'. At most
+		// 1MiB the handlers left unread is copied once they returned.
+		// Optional. Default value nil.
+		BodySink BodySink `yaml:"-"`
+
+		// Outputs lists several writers receiving every entry, e.g. a local
+		// file and a collector. The first one replaces Output. The entry is
+		// rendered once per color setting, so a terminal gets colors while
+		// files and pipes do not. In Async mode each writer has its own
+		// queue.
+		// Optional. Default value nil (use Output).
+		Outputs []io.Writer
+
+		// Async writes entries to Output from a background goroutine
+		// through a queue of QueueSize entries. When the queue is full new
+		// entries are dropped and counted in Stats.Dropped, unless
+		// BlockOnFull is set. ErrorOutput, AbortedOutput and ShadowOutput
+		// get queues of their own, or share the one of Output when they are
+		// the same writer. Call Middleware.Close on shutdown to drain the
+		// queues.
+		// Optional. Default value false.
+		Async bool `yaml:"async"`
+
+		// QueueSize is the capacity of the Async queue.
+		// Optional. Default value DefaultLoggerConfig.QueueSize.
+		QueueSize int `yaml:"queue_size"`
+
+		// BlockOnFull makes requests wait for room in a full Async queue
+		// instead of dropping the entry.
+		// Optional. Default value false.
+		BlockOnFull bool `yaml:"block_on_full"`
+
+		// FlushInterval batches the entries of Async mode: they are written
+		// to Output in one call once BatchSize bytes are accumulated, and at
+		// the latest after FlushInterval, so a quiet service still sees its
+		// lines promptly. Setting it enables Async. Middleware.Flush and
+		// Close write the batch immediately.
+		// Optional. Default value 0 (every entry is written on its own).
+		FlushInterval time.Duration `yaml:"flush_interval"`
+
+		// BatchSize is the number of bytes after which a FlushInterval
+		// batch is written before the interval elapses.
+		// Optional. Default value DefaultLoggerConfig.BatchSize.
+		BatchSize int `yaml:"batch_size"`
+
+		// AbortedOutput receives the entries of requests aborted by a
+		// handler, e.g. with ctx.AbortWithStatusJSON in an auth or rate
+		// limit middleware, instead of Output.
+		// Optional. Default value nil (aborted requests go to Output).
+		AbortedOutput io.Writer
+
+		// AbortedLevel replaces the level of aborted requests, e.g. "info"
+		// so expected rejections do not count as warnings. It does not
+		// apply to requests at level "error" (5xx or errors recorded with
+		// ctx.Error), and slow requests are still at least "warn". Unknown
+		// level names are rejected.
+		// Optional. Default value "" (the level is derived as usual).
+		AbortedLevel string `yaml:"aborted_level"`
+
+		// ErrorOutput additionally receives the entries at level "error",
+		// e.g. os.Stderr. Colors are decided separately for each writer.
+		// Optional. Default value nil.
+		ErrorOutput io.Writer
+
+		// ErrorOutputOnly writes the entries at level "error" to
+		// ErrorOutput instead of Output, e.g. to split access logs on
+		// os.Stdout from error logs on os.Stderr.
+		// Optional. Default value false.
+		ErrorOutputOnly bool `yaml:"error_output_only"`
+
+		// DiagnosticsOutput receives one line the first time each runtime
+		// misconfiguration is detected, e.g. an unknown tag or the app_id
+		// tag without a value in the context. See also Middleware.Stats.
+		// Optional. Default value os.Stderr.
+		DiagnosticsOutput io.Writer
+
+		// EncryptFields lists the tags whose values are logged encrypted,
+		// e.g. "body" or "context:user". Each value is replaced with an
+		// "enc:<key id>:<base64>" envelope sealed with a key generated for
+		// the process. That key is written once, wrapped with
+		// EncryptionKey, in a "glog key" line to every writer receiving
+		// entries (Outputs, ErrorOutput, AbortedOutput and ShadowOutput)
+		// when the middleware is built and on Middleware.RotateKey. Use
+		// Decrypt to recover a value with the private key. Names that are
+		// not tags of the format are rejected.
+		// Optional. Default value nil.
+		EncryptFields []string `yaml:"encrypt_fields"`
+
+		// EncryptionKey wraps the key of EncryptFields with RSA-OAEP and
+		// SHA-256. Required when EncryptFields is set.
+		EncryptionKey *rsa.PublicKey `yaml:"-"`
+
+		// MinLevel drops entries below the level: "info", "warn" or
+		// "error". Other names are rejected.
+		// Optional. Default value "" (log every level).
+		MinLevel string `yaml:"min_level"`
+
+		template  *fasttemplate.Template
+		redactor  *regexp.Regexp
+		sensitive map[string]struct{}
+		// maskedHeaders, maskedCookies and excludedHeaders hold the
+		// lowercased names.
+		maskedHeaders   map[string]struct{}
+		maskedCookies   map[string]struct{}
+		excludedHeaders map[string]struct{}
+		colorer         *color.Color
+		errColorer      *color.Color
+		abortColorer    *color.Color
+		pool            *sync.Pool
+		chain           *hashChain
+		skip            skipMatcher
+		exempt          map[string]struct{}
+		adaptive        *adaptiveSampler
+		async           *asyncWriter
+		diag            *diagnostics
+		budget          *clientBudget
+		encrypter       *fieldEncrypter
+		dryRun          *dryRunBuffer
+		routeStats      *routeStats
+		routeInfo       *routeInfo
+		extraOutputs    []*extraOutput
+		// sideAsync holds the async writers of ErrorOutput, AbortedOutput
+		// and ShadowOutput, see asyncSideOutputs.
+		sideAsync []*asyncWriter
+		// fastPath is set when FastPath applies to Format.
+		fastPath bool
+		// needError is set when the entries or Hook use the error text.
+		needError bool
+		// minLevel is MinLevel, LevelDebug when it is empty.
+		minLevel Level
+		// formatOption names the New option that set the format, see
+		// claimFormat.
+		formatOption string
+		// treatContextErrorAs and abortedLevel are TreatContextErrorAs
+		// and AbortedLevel parsed.
+		treatContextErrorAs, abortedLevel Level
+		// dropped and outputFailures point to the counters of the
+		// Middleware, see Stats.
+		dropped        *uint64
+		outputFailures *uint64
+		// staticFields is StaticFields with the environment variables
+		// resolved, env those of the env:<NAME> tags.
+		staticFields map[string]string
+		env          map[string]string
+		// keyCase converts the Fields keys, nil keeps them.
+		keyCase func(string) string
+		// now times the requests and drives TargetRate and ClientBudget,
+		// time.Now unless set by the tests.
+		now func() time.Time
+		// random draws the sampling decisions, rand.Float64 unless set by
+		// the tests.
+		random func() float64
+
+		shadowTemplate *fasttemplate.Template
+		shadowColorer  *color.Color
+		// tags holds the tags used by Format or Fields, bodies are only
+		// captured when they are referenced.
+		tags map[string]struct{}
+	}
+
+	// Middleware is a Logger middleware instance built from a LoggerConfig.
+	Middleware struct {
+		// seq is incremented once per logged entry. Entries are numbered
+		// before they are written, so when Output is shared by concurrent
+		// requests the order of lines in the sink may differ from seq
+		// order; sort by seq to recover the true ordering.
+		seq uint64
+		// shadowFailures counts the ShadowFormat entries that could not
+		// be rendered or written.
+		shadowFailures uint64
+		// dropped counts the entries that could not be written to Output,
+		// or to AbortedOutput or ErrorOutput when they replace it.
+		dropped uint64
+		// outputFailures counts the failed writes to Outputs[1:] and the
+		// ErrorOutput copies of error entries.
+		outputFailures uint64
+
+		config LoggerConfig
+	}
+
+	// Stats is a snapshot of the runtime state of a Middleware.
+	Stats struct {
+		// SampleRate is the current adaptive sample probability, 1 when
+		// TargetRate is not set.
+		SampleRate float64
+		// ShadowFailures is the number of ShadowFormat entries that could
+		// not be rendered or written.
+		ShadowFailures uint64
+		// Dropped is the number of entries that could not be written to
+		// Output, or to AbortedOutput or ErrorOutput when they replace it:
+		// the writer was nil, returned an error or panicked, or the Async
+		// queue was full.
+		Dropped uint64
+		// OutputFailures is the number of failed writes to Outputs[1:] and
+		// of the ErrorOutput copies of error entries.
+		OutputFailures uint64
+		// RouteInfoFailures is the number of RouteInfoProvider calls that
+		// panicked.
+		RouteInfoFailures uint64
+		// Warnings lists the misconfigurations detected at runtime, see
+		// LoggerConfig.DiagnosticsOutput.
+		Warnings []string
 	}
 
 	bodyLogWriter struct {
 		gin.ResponseWriter
-		body *bytes.Buffer
+		body *limitedBuffer
+		// types is LoggerConfig.BodyContentTypes.
+		types []string
 	}
 )
 
@@ -87,141 +821,1075 @@ var (
 			`"host":"${host}","method":"${method}","uri":"${uri}","user_agent":"${user_agent}",` +
 			`"status":${status},"error":"${error}","latency":${latency},"latency_human":"${latency_human}"` +
 			`,"bytes_in":${bytes_in},"bytes_out":${bytes_out}}` + "\n",
-		CustomTimeFormat: "2006-01-02 15:04:05.00000",
-		Output:           os.Stdout,
-		colorer:          color.New(),
+		CustomTimeFormat:     "2006-01-02 15:04:05.00000",
+		RequestIDHeader:      "X-Request-ID",
+		SinkHeader:           "X-Glog-Sink",
+		GenerateRequestID:    true,
+		EscapeJSON:           true,
+		SeverityNumbers:      map[Level]int{LevelInfo: 6, LevelWarn: 4, LevelError: 3},
+		ResponseHeadSize:     256,
+		FingerprintFields:    []string{"method", "route", "query_names", "ua_family", "ip_prefix"},
+		QueueSize:            1024,
+		BatchSize:            64 << 10,
+		ClientBudgetInterval: time.Minute,
+		ClientBudgetKey:      "remote_ip",
+		ClientBudgetSize:     10000,
+		DryRunSize:           100,
+		SensitiveFields:      []string{"password"},
+		MaskedHeaders:        []string{"Authorization", "Cookie", "Set-Cookie"},
+		TreatContextErrorAs:  "error",
+		Output:               os.Stdout,
+		colorer:              color.New(),
 	}
 )
 
+// Logger returns a Logger middleware with the default config.
+func Logger() gin.HandlerFunc {
+	// LoggerWithConfig takes the config by value, the shared default is
+	// never modified.
+	return LoggerWithConfig(DefaultLoggerConfig)
+}
+
 // LoggerWithConfig returns a Logger middleware with config.
 // See: `Logger()`.
+// It panics when the format uses an invalid tag argument.
 func LoggerWithConfig(config LoggerConfig) gin.HandlerFunc {
+	m, err := newMiddleware(config)
+	if err != nil {
+		panic(err)
+	}
+	return m.handle
+}
+
+// newMiddleware fills in the defaults of config and prepares it for use.
+func newMiddleware(config LoggerConfig) (*Middleware, error) {
+	m := new(Middleware)
+	config.dropped, config.outputFailures = &m.dropped, &m.outputFailures
+	if config.now == nil {
+		config.now = time.Now
+	}
+	if config.random == nil {
+		config.random = rand.Float64
+	}
 	if config.Format == "" {
+		// The default format is JSON and logs the id, whatever the zero
+		// values of the other fields.
 		config.Format = DefaultLoggerConfig.Format
+		config.GenerateRequestID = true
+	}
+	if strings.HasPrefix(config.Format, "{") {
+		config.EscapeJSON = true
+	}
+	if len(config.Outputs) > 0 {
+		config.Output = config.Outputs[0]
 	}
 	if config.Output == nil {
 		config.Output = DefaultLoggerConfig.Output
 	}
+	if config.DryRun {
+		if config.DryRunSize <= 0 {
+			config.DryRunSize = DefaultLoggerConfig.DryRunSize
+		}
+		config.dryRun = newDryRunBuffer(config.DryRunSize)
+		config.Output = config.dryRun
+		config.Outputs = nil
+		for _, w := range []*io.Writer{&config.ErrorOutput, &config.ShadowOutput, &config.AbortedOutput} {
+			if *w != nil {
+				*w = config.dryRun
+			}
+		}
+	}
+	if config.FingerprintFields == nil {
+		config.FingerprintFields = DefaultLoggerConfig.FingerprintFields
+	}
+	if config.RequestIDHeader == "" {
+		config.RequestIDHeader = DefaultLoggerConfig.RequestIDHeader
+	}
+	if config.SinkHeader == "" {
+		config.SinkHeader = DefaultLoggerConfig.SinkHeader
+	}
+	if config.SinkSecret == "" {
+		config.SinkSecret = processSinkSecret
+	}
+	if config.SensitiveFields == nil {
+		config.SensitiveFields = DefaultLoggerConfig.SensitiveFields
+	}
+	if config.SeverityNumbers == nil {
+		config.SeverityNumbers = DefaultLoggerConfig.SeverityNumbers
+	}
+	if config.TreatContextErrorAs == "" {
+		config.TreatContextErrorAs = DefaultLoggerConfig.TreatContextErrorAs
+	}
+	level, err := ParseLevel(config.TreatContextErrorAs)
+	if err != nil {
+		return nil, fmt.Errorf("%v in TreatContextErrorAs", err)
+	}
+	config.treatContextErrorAs = level
+	if config.MinLevel != "" {
+		level, err := ParseLevel(config.MinLevel)
+		if err != nil {
+			return nil, fmt.Errorf("%v in MinLevel", err)
+		}
+		config.minLevel = level
+	}
+	if config.AbortedLevel != "" {
+		level, err := ParseLevel(config.AbortedLevel)
+		if err != nil {
+			return nil, fmt.Errorf("%v in AbortedLevel", err)
+		}
+		config.abortedLevel = level
+	}
+	if config.DiagnosticsOutput == nil {
+		config.DiagnosticsOutput = os.Stderr
+	}
+	config.diag = &diagnostics{out: config.DiagnosticsOutput}
+	config.skip = skipMatcher{}
+	for p := range config.Skip {
+		config.skip.add(p)
+	}
+	for _, p := range config.SkipPaths {
+		config.skip.add(p)
+	}
+	config.exempt = make(map[string]struct{}, len(config.NeverSample))
+	for _, p := range config.NeverSample {
+		config.exempt[p] = struct{}{}
+	}
+	if config.Structured && config.Fields == nil {
+		config.Fields = DefaultStructuredFields
+	}
 	config.template = fasttemplate.New(config.Format, "${", "}")
-	config.colorer = color.New()
-	config.colorer.SetOutput(config.Output)
+	config.tags = make(map[string]struct{})
+	if config.Fields != nil {
+		for _, tag := range config.Fields {
+			config.tags[tag] = struct{}{}
+		}
+	} else {
+		_, _ = config.template.ExecuteFunc(ioutil.Discard, func(w io.Writer, tag string) (int, error) {
+			config.tags[tag] = struct{}{}
+			return 0, nil
+		})
+	}
+	if config.ShadowFormat != "" && config.ShadowOutput != nil {
+		// Capture decisions take the union of both formats' tags.
+		config.shadowTemplate = fasttemplate.New(config.ShadowFormat, "${", "}")
+		_, _ = config.shadowTemplate.ExecuteFunc(ioutil.Discard, func(w io.Writer, tag string) (int, error) {
+			config.tags[tag] = struct{}{}
+			return 0, nil
+		})
+		config.shadowColorer = config.newColorer(config.ShadowOutput)
+	}
+	config.staticFields, config.env = resolveStatic(config.StaticFields, config.tags)
+	caser, ok := keyCaser(config.KeyCase)
+	if !ok {
+		return nil, fmt.Errorf("glog: invalid key case %q", config.KeyCase)
+	}
+	config.keyCase = caser
+	if err := config.keyCollision(config.Fields, config.StaticFields); err != nil {
+		return nil, err
+	}
+	_, errorTag := config.tags["error"]
+	_, extrasTag := config.tags["extras"]
+	config.needError = errorTag || extrasTag || config.Hook != nil
+	if err := validateTags(config.tags); err != nil {
+		return nil, err
+	}
+	if err := validateCaptureOverrides(config.RouteCaptureOverrides); err != nil {
+		return nil, err
+	}
+	if config.ResponseHeadSize <= 0 {
+		config.ResponseHeadSize = DefaultLoggerConfig.ResponseHeadSize
+	}
+	if config.MaxBodyLogSize == 0 {
+		config.MaxBodyLogSize = config.MaxBodySize
+	}
+	if config.MaxResponseLogSize == 0 {
+		config.MaxResponseLogSize = config.MaxBodySize
+	}
+	config.redactor = newRedactor(config.SensitiveFields)
+	config.sensitive = lowerSet(config.SensitiveFields)
+	if config.MaskedHeaders == nil {
+		config.MaskedHeaders = DefaultLoggerConfig.MaskedHeaders
+	}
+	config.maskedHeaders = lowerSet(config.MaskedHeaders)
+	config.excludedHeaders = lowerSet(config.ExcludedHeaders)
+	config.maskedCookies = lowerSet(config.MaskedCookies)
+	config.colorer = config.newColorer(config.Output)
+	config.errColorer = config.newColorer(config.ErrorOutput)
+	config.abortColorer = config.newColorer(config.AbortedOutput)
+	if config.TargetRate > 0 {
+		config.adaptive = newAdaptiveSampler(config.TargetRate, config.now)
+	}
+	if _, ok := latencyUnits[config.LatencyUnit]; config.LatencyUnit != 0 && !ok {
+		return nil, fmt.Errorf("glog: invalid latency unit %v", config.LatencyUnit)
+	}
+	if config.ClientBudget > 0 {
+		if config.ClientBudgetInterval <= 0 {
+			config.ClientBudgetInterval = DefaultLoggerConfig.ClientBudgetInterval
+		}
+		if config.ClientBudgetKey == "" {
+			config.ClientBudgetKey = DefaultLoggerConfig.ClientBudgetKey
+		}
+		if !validBudgetKey(config.ClientBudgetKey) {
+			return nil, fmt.Errorf("glog: invalid client budget key %q", config.ClientBudgetKey)
+		}
+		if config.ClientBudgetSize <= 0 {
+			config.ClientBudgetSize = DefaultLoggerConfig.ClientBudgetSize
+		}
+		config.budget = newClientBudget(config.ClientBudget, config.ClientBudgetInterval, config.ClientBudgetSize, config.now)
+	}
+	// Everything that can fail is checked above the first goroutine and
+	// the first line written.
+	var key *keyLine
+	if len(config.EncryptFields) > 0 {
+		if config.EncryptionKey == nil {
+			return nil, errors.New("glog: EncryptFields needs an EncryptionKey")
+		}
+		for _, tag := range config.EncryptFields {
+			if _, ok := config.tags[tag]; !ok {
+				return nil, fmt.Errorf("glog: EncryptFields names %q, not a tag of the format", tag)
+			}
+		}
+		config.encrypter = newFieldEncrypter(config.EncryptionKey, config.EncryptFields)
+		var err error
+		if key, err = config.encrypter.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.TamperEvident {
+		config.chain = new(hashChain)
+	}
 	config.pool = &sync.Pool{
 		New: func() interface{} {
-			return bytes.NewBuffer(make([]byte, 256))
+			return bytes.NewBuffer(make([]byte, 0, 256))
 		},
 	}
-	return func(ctx *gin.Context) {
-		bodyBytes, _ := ctx.GetRawData()
-		// Restore the io.ReadCloser to its original state
-		ctx.Request.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
-		path := ctx.Request.URL.Path
-		raw := ctx.Request.URL.RawQuery
-		start := time.Now()
-		resBody := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: ctx.Writer}
-		ctx.Writer = resBody
+	if config.Async || config.FlushInterval > 0 {
+		config.Async = true
+		if config.QueueSize <= 0 {
+			config.QueueSize = DefaultLoggerConfig.QueueSize
+		}
+		if config.BatchSize <= 0 {
+			config.BatchSize = DefaultLoggerConfig.BatchSize
+		}
+		config.async = newAsyncWriter(config.Output, config.dropped, config.QueueSize, config.BlockOnFull, config.FlushInterval, config.BatchSize)
+	}
+	config.extraOutputs = config.newExtraOutputs()
+	if config.Async {
+		config.asyncSideOutputs(&m.shadowFailures)
+	}
+	if config.LogStartupConfig {
+		config.writeStartupConfig()
+	}
+	if key != nil {
+		config.writeKey(key)
+	}
+	if config.budget != nil {
+		config.startBudget()
+	}
+	config.fastPath = config.fastPathEligible()
+	if config.CollectStats {
+		config.routeStats = new(routeStats)
+	}
+	if config.RouteInfoProvider != nil {
+		config.routeInfo = newRouteInfo(config.RouteInfoProvider)
+	}
+	if config.LogStartupMarker {
+		config.writeMarker("logger_start")
+	}
+	m.config = config
+	return m, nil
+}
 
+// Stats returns a snapshot of the runtime state of the middleware.
+func (m *Middleware) Stats() Stats {
+	s := Stats{
+		SampleRate:     1,
+		ShadowFailures: atomic.LoadUint64(&m.shadowFailures),
+		Dropped:        atomic.LoadUint64(&m.dropped),
+		OutputFailures: atomic.LoadUint64(&m.outputFailures),
+		Warnings:       m.config.diag.list(),
+	}
+	if m.config.adaptive != nil {
+		s.SampleRate = m.config.adaptive.current()
+	}
+	if m.config.routeInfo != nil {
+		s.RouteInfoFailures = atomic.LoadUint64(&m.config.routeInfo.failures)
+	}
+	return s
+}
+
+// Flush waits until the entries queued in Async mode are written. It does
+// nothing otherwise.
+func (m *Middleware) Flush() {
+	if m.config.async != nil {
+		m.config.async.Flush()
+	}
+	for _, o := range m.config.extraOutputs {
+		if o.async != nil {
+			o.async.Flush()
+		}
+	}
+	for _, a := range m.config.sideAsync {
+		a.Flush()
+	}
+}
+
+// Close writes the pending ClientBudget summaries, drains the Async queues
+// and stops their goroutines; entries logged afterwards are dropped.
+// Output itself is not closed.
+func (m *Middleware) Close() error {
+	if m.config.budget != nil {
+		m.config.stopBudget()
+	}
+	if m.config.LogStartupMarker {
+		m.config.writeMarker("logger_stop")
+	}
+	for _, o := range m.config.extraOutputs {
+		if o.async != nil {
+			o.async.Close()
+		}
+	}
+	for _, a := range m.config.sideAsync {
+		a.Close()
+	}
+	if m.config.async != nil {
+		return m.config.async.Close()
+	}
+	return nil
+}
+
+func (m *Middleware) handle(ctx *gin.Context) {
+	config := &m.config
+	path := ctx.Request.URL.Path
+	if config.LoopProtection && config.fromSink(ctx.Request) {
+		ctx.Next()
+		return
+	}
+	if config.skip.match(path) || config.Skipper != nil && config.Skipper(ctx) {
+		if config.Hook != nil {
+			config.hookSkipped(ctx)
+			return
+		}
 		ctx.Next()
-		level := "info"
-		err, ok := ctx.Get(ContextError)
-		if ok {
-			level = "error"
-		}
-		errInfo, _ := json.Marshal(err)
-		if _, ok := config.Skip[path]; !ok {
-			stop := time.Now()
-
-			buf := config.pool.Get().(*bytes.Buffer)
-			buf.Reset()
-			defer config.pool.Put(buf)
-			re := regexp.MustCompile("\n *|\"password.*\":\".+?\",*")
-			if _, err := config.template.ExecuteFunc(buf, func(w io.Writer, tag string) (int, error) {
-				switch tag {
-				case "time_unix":
-					return buf.WriteString(strconv.FormatInt(time.Now().Unix(), 10))
-				case "time_unix_nano":
-					return buf.WriteString(strconv.FormatInt(time.Now().UnixNano(), 10))
-				case "time_rfc3339":
-					return buf.WriteString(time.Now().Format(time.RFC3339))
-				case "time_rfc3339_nano":
-					return buf.WriteString(time.Now().Format(time.RFC3339Nano))
-				case "time_custom":
-					return buf.WriteString(time.Now().Format(config.CustomTimeFormat))
-				case "remote_ip":
-					return buf.WriteString(ctx.ClientIP())
-				case "host":
-					return buf.WriteString(ctx.Request.Host)
-				case "uri":
-					return buf.WriteString(ctx.Request.RequestURI)
-				case "method":
-					return buf.WriteString(ctx.Request.Method)
-				case "path":
-					if path == "" {
-						path = "/"
-					}
-					return buf.WriteString(path)
-				case "query":
-					return buf.WriteString(raw)
-				case "protocol":
-					return buf.WriteString(ctx.Request.Proto)
-				case "referer":
-					return buf.WriteString(ctx.Request.Referer())
-				case "user_agent":
-					return buf.WriteString(ctx.Request.UserAgent())
-				case "status":
-					n := ctx.Writer.Status()
-					s := config.colorer.Green(n)
-					switch {
-					case n >= 500:
-						s = config.colorer.Red(n)
-					case n >= 400:
-						s = config.colorer.Yellow(n)
-					case n >= 300:
-						s = config.colorer.Cyan(n)
-					}
-					return buf.WriteString(s)
-				case "app_id":
-					appID, _ := ctx.Get(ContextError)
-					return buf.WriteString(appID.(string))
-				case "level":
-					return buf.WriteString(level)
-				case "error":
-					return buf.Write(errInfo)
-				case "latency":
-					l := stop.Sub(start)
-					return buf.WriteString(strconv.FormatInt(int64(l), 10))
-				case "latency_human":
-					return buf.WriteString(stop.Sub(start).String())
-				case "body":
-					return buf.WriteString(re.ReplaceAllString(string(bodyBytes), ""))
-				case "response":
-					return buf.WriteString(re.ReplaceAllString(resBody.body.String(), ""))
-				default:
-					switch {
-					case strings.HasPrefix(tag, "header:"):
-						return buf.Write([]byte(ctx.Request.Header.Get(tag[7:])))
-					case strings.HasPrefix(tag, "query:"):
-						return buf.Write([]byte(ctx.Query(tag[6:])))
-					case strings.HasPrefix(tag, "form:"):
-						return buf.Write([]byte(ctx.Request.FormValue(tag[5:])))
-					case strings.HasPrefix(tag, "cookie:"):
-						cookie, err := ctx.Cookie(tag[7:])
-						if err == nil {
-							return buf.Write([]byte(cookie))
-						}
-					}
+		return
+	}
+	// The route is already known: gin resolves it before the chain runs.
+	capture := config.RouteCaptureOverrides[ctx.FullPath()]
+	reqBody := &requestBody{}
+	if _, ok := config.tags["body"]; ok && !capture.DisableCapture {
+		reqBody = captureBody(ctx.Request, capture.limit(config.MaxBodyLogSize), config.LogMultipartBody, config.BodyContentTypes)
+		if reqBody.complete {
+			ctx.Set(ContextRequestBody, reqBody.buf.Bytes())
+		}
+	}
+	raw := ctx.Request.URL.RawQuery
+	start := config.now()
+	requestID := ctx.Request.Header.Get(config.RequestIDHeader)
+	if config.BodySink != nil && !validSinkKey(requestID) {
+		// The ID names the artifacts, never let the client pick a path.
+		requestID = ""
+	}
+	if requestID == "" && (config.GenerateRequestID || config.BodySink != nil) {
+		requestID = newRequestID()
+	}
+	if requestID != "" {
+		ctx.Set(ContextRequestID, requestID)
+		ctx.Header(config.RequestIDHeader, requestID)
+	}
+	var sent *sentWriter
+	_, sentTag := config.tags["bytes_sent"]
+	if _, ok := config.tags["write_error"]; ok || sentTag {
+		sent = &sentWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = sent
+	}
+	var flushed *flushWriter
+	if _, ok := config.tags["transfer_encoding"]; ok {
+		flushed = &flushWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = flushed
+	}
+	var resBody *bodyLogWriter
+	if _, ok := config.tags["response"]; ok && !capture.DisableCapture {
+		resBody = &bodyLogWriter{body: &limitedBuffer{limit: capture.limit(config.MaxResponseLogSize)}, types: config.BodyContentTypes, ResponseWriter: ctx.Writer}
+		ctx.Writer = resBody
+	} else if _, ok := config.tags["response_head"]; ok && !capture.DisableCapture {
+		// Only the head is needed, keep memory bounded by its size.
+		resBody = &bodyLogWriter{body: &limitedBuffer{limit: config.ResponseHeadSize}, types: config.BodyContentTypes, ResponseWriter: ctx.Writer}
+		ctx.Writer = resBody
+	}
+	if config.BodySink != nil {
+		defer config.teeBodies(ctx, requestID)()
+	}
+	var upstream *upstreamTimer
+	if _, ok := config.tags["upstream_latency"]; ok {
+		upstream = trackUpstream(ctx)
+	}
+
+	var allocs uint64
+	if config.LogAllocs {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		allocs = ms.TotalAlloc
+	}
+	panicked := config.next(ctx)
+	if config.ExposeResponseBody && resBody != nil && resBody.omitted() == "" && resBody.body.size == int64(resBody.body.Len()) {
+		ctx.Set(ContextResponseBody, resBody.body.Bytes())
+	}
+	if panicked != nil && config.repanic(panicked) {
+		// Runs last, after the entry is written.
+		defer panic(panicked.value)
+	}
+	stop := config.now()
+	if config.LogAllocs {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		allocs = ms.TotalAlloc - allocs
+	}
+	config.logSlowDependencies(ctx, requestID, stop)
+	aborted := ctx.IsAborted()
+	slow := config.SlowThreshold > 0 && stop.Sub(start) >= config.SlowThreshold
+	level := config.entryLevel(ctx, aborted, slow)
+	var errInfo string
+	if config.needError {
+		// Only pay for formatting the errors when they are logged.
+		errInfo = errorText(ctx)
+	}
+	bytesIn := func() int64 {
+		if n := ctx.Request.ContentLength; n >= 0 {
+			return n
+		}
+		return reqBody.buf.size
+	}
+	bytesOut := func() int {
+		if sent != nil {
+			return sent.written
+		}
+		if n := ctx.Writer.Size(); n >= 0 {
+			return n
+		}
+		return 0
+	}
+	if config.routeStats != nil {
+		config.routeStats.add(ctx.FullPath(), stop.Sub(start), level)
+	}
+	var sampleRate float64
+	logged := !config.filtered(ctx.Writer.Status(), stop.Sub(start), level)
+	if logged {
+		sampleRate, logged = config.sample(ctx, level, stop.Sub(start))
+		logged = logged && config.withinBudget(ctx)
+	}
+	var seqNo uint64
+	if logged {
+		seqNo = atomic.AddUint64(&m.seq, 1)
+	}
+	if config.Hook != nil {
+		config.runHook(LogEntry{
+			Method:    config.method(ctx.Request),
+			Path:      path,
+			Route:     ctx.FullPath(),
+			Status:    ctx.Writer.Status(),
+			Latency:   stop.Sub(start),
+			BytesIn:   bytesIn(),
+			BytesOut:  bytesOut(),
+			ClientIP:  ctx.ClientIP(),
+			Level:     level,
+			Error:     errInfo,
+			AppID:     contextValue(ctx, ContextAppID),
+			RequestID: requestID,
+			Seq:       seqNo,
+		})
+	}
+	if !logged {
+		return
+	}
+	ts := stop
+	if config.TimeAtStart {
+		ts = start
+	}
+
+	// A buffer goes back to the pool only when the handler returns.
+	// Writers are called synchronously and, as required by io.Writer,
+	// must not retain the slice; the Async writer copies it. Every
+	// output gets its own buffer so a second rendering never touches
+	// bytes already handed to a writer.
+	buf := config.pool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer config.putBuffer(buf)
+	var (
+		cookies       []*http.Cookie
+		cookiesParsed bool
+	)
+	var traceID, spanID string
+	traceParsed := false
+	traceIDs := func() (string, string) {
+		if !traceParsed {
+			traceID, spanID = config.traceIDs(ctx.Request)
+			traceParsed = true
+		}
+		return traceID, spanID
+	}
+	var (
+		info       map[string]string
+		infoLoaded bool
+	)
+	routeInfo := func() map[string]string {
+		if !infoLoaded && config.routeInfo != nil {
+			info, infoLoaded = config.routeInfo.lookup(ctx), true
+		}
+		return info
+	}
+	requestCookies := func() []*http.Cookie {
+		if !cookiesParsed {
+			cookies, cookiesParsed = ctx.Request.Cookies(), true
+		}
+		return cookies
+	}
+	if config.chain != nil {
+		config.chain.mu.Lock()
+		defer config.chain.mu.Unlock()
+	}
+	// colorer is switched to the one of ErrorOutput when the entry is
+	// rendered again for it.
+	colorer := config.colorer
+	// out replaces Output for aborted requests with AbortedOutput and
+	// errors with ErrorOutputOnly.
+	var out io.Writer
+	switch {
+	case level == LevelError && config.ErrorOutput != nil && config.ErrorOutputOnly:
+		out, colorer = config.ErrorOutput, config.errColorer
+	case aborted && config.AbortedOutput != nil:
+		out, colorer = config.AbortedOutput, config.abortColorer
+	}
+	redact := config.redact
+	if capture.DisableRedaction {
+		redact = func(s string) string { return s }
+	}
+	writeTag := func(buf *bytes.Buffer, tag string) (int, error) {
+		switch tag {
+		case "time_unix":
+			return buf.WriteString(strconv.FormatInt(ts.Unix(), 10))
+		case "time_unix_nano":
+			return buf.WriteString(strconv.FormatInt(ts.UnixNano(), 10))
+		case "time_rfc3339":
+			return buf.WriteString(ts.Format(time.RFC3339))
+		case "time_rfc3339_nano":
+			return buf.WriteString(ts.Format(time.RFC3339Nano))
+		case "time_custom":
+			return buf.WriteString(ts.Format(config.CustomTimeFormat))
+		case "id":
+			return buf.WriteString(requestID)
+		case "remote_ip":
+			return buf.WriteString(ctx.ClientIP())
+		case "host":
+			return buf.WriteString(ctx.Request.Host)
+		case "uri":
+			return buf.WriteString(ctx.Request.RequestURI)
+		case "method":
+			return buf.WriteString(config.method(ctx.Request))
+		case "raw_method":
+			return buf.WriteString(ctx.Request.Method)
+		case "path":
+			if path == "" {
+				path = "/"
+			}
+			return buf.WriteString(path)
+		case "query":
+			return buf.WriteString(config.redactQuery(raw, false))
+		case "query_decoded":
+			return buf.WriteString(config.redactQuery(raw, true))
+		case "transfer_encoding":
+			return buf.WriteString(transferEncoding(ctx, flushed.flushed))
+		case "middleware_timings":
+			return buf.Write(middlewareTimings(ctx))
+		case "request_headers":
+			return buf.Write(config.headersJSON(ctx.Request.Header))
+		case "response_headers":
+			return buf.Write(config.headersJSON(ctx.Writer.Header()))
+		case "params_object":
+			params := make(map[string]string, len(ctx.Params))
+			for _, p := range ctx.Params {
+				params[p.Key] = p.Value
+			}
+			b, _ := json.Marshal(params)
+			return buf.Write(b)
+		case "protocol":
+			return buf.WriteString(ctx.Request.Proto)
+		case "request_fingerprint":
+			return buf.WriteString(config.fingerprint(ctx))
+		case "cookie_count":
+			return buf.WriteString(strconv.Itoa(len(requestCookies())))
+		case "cookies":
+			for i, c := range requestCookies() {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				buf.WriteString(c.Name)
+			}
+			return 0, nil
+		case "referer":
+			return buf.WriteString(ctx.Request.Referer())
+		case "origin":
+			return buf.WriteString(ctx.Request.Header.Get("Origin"))
+		case "cors_allowed":
+			return buf.WriteString(strconv.FormatBool(corsAllowed(ctx)))
+		case "user_agent":
+			return buf.WriteString(ctx.Request.UserAgent())
+		case "status":
+			return buf.WriteString(statusText(colorer, ctx.Writer.Status()))
+		case "app_id":
+			if _, ok := ctx.Get(ContextAppID); !ok {
+				config.diag.warnOnce("app_id", "tag app_id is used but "+ContextAppID+" is not set in the context")
+			}
+			return buf.WriteString(contextValue(ctx, ContextAppID))
+		case "sample_rate":
+			return buf.WriteString(strconv.FormatFloat(sampleRate, 'g', -1, 64))
+		case "seq":
+			return buf.WriteString(strconv.FormatUint(seqNo, 10))
+		case "prev_hash":
+			if config.chain != nil {
+				return buf.WriteString(config.chain.prevHash())
+			}
+		case "open_fds":
+			n := -1
+			if config.LogOpenFDs && level == LevelError {
+				n = openFDs()
+			}
+			return buf.WriteString(strconv.Itoa(n))
+		case "heap_alloc":
+			if config.LogMemStats && level == LevelError {
+				var m runtime.MemStats
+				runtime.ReadMemStats(&m)
+				return buf.WriteString(strconv.FormatUint(m.HeapAlloc, 10))
+			}
+			return buf.WriteString("-1")
+		case "alloc_bytes":
+			if config.LogAllocs {
+				return buf.WriteString(strconv.FormatUint(allocs, 10))
+			}
+			return buf.WriteString("-1")
+		case "level":
+			return buf.WriteString(level.String())
+		case "status_explicit":
+			return buf.WriteString(strconv.FormatBool(ctx.Writer.Written() || ctx.Writer.Status() != http.StatusOK))
+		case "aborted":
+			return buf.WriteString(strconv.FormatBool(aborted))
+		case "trace_id":
+			t, _ := traceIDs()
+			return buf.WriteString(t)
+		case "span_id":
+			_, s := traceIDs()
+			return buf.WriteString(s)
+		case "stack":
+			if config.EscapeJSON && panicked != nil {
+				// Escaped with the other tags.
+				return buf.Write(panicked.stack)
+			}
+			return buf.WriteString(panicked.stackText())
+		case "matched":
+			return buf.WriteString(strconv.FormatBool(ctx.FullPath() != ""))
+		case "level_value":
+			return buf.WriteString(strconv.Itoa(int(level)))
+		case "severity_number":
+			return buf.WriteString(strconv.Itoa(config.SeverityNumbers[level]))
+		case "slow":
+			return buf.WriteString(strconv.FormatBool(slow))
+		case "error":
+			return buf.WriteString(errInfo)
+		case "latency":
+			l := stop.Sub(start)
+			return buf.WriteString(strconv.FormatInt(int64(l), 10))
+		case "upstream_latency":
+			return buf.WriteString(strconv.FormatInt(int64(upstreamLatency(ctx, upstream)), 10))
+		case "latency_human":
+			return buf.WriteString(config.latencyText(colorer, stop.Sub(start)))
+		case "latency_console":
+			return fmt.Fprintf(buf, "%10v", stop.Sub(start))
+		case "remote_ip_console":
+			return fmt.Fprintf(buf, "%15s", ctx.ClientIP())
+		case "method_color":
+			return buf.WriteString(colorMethod(colorer, config.method(ctx.Request)))
+		case "extras":
+			return writeExtras(buf, level, errInfo, contextValue(ctx, ContextAppID))
+		case "bytes_in":
+			return buf.WriteString(strconv.FormatInt(bytesIn(), 10))
+		case "bytes_out":
+			return buf.WriteString(strconv.Itoa(bytesOut()))
+		case "bytes_sent":
+			return buf.WriteString(strconv.Itoa(sent.sent))
+		case "write_error":
+			return buf.WriteString(sent.errorText())
+		case "body":
+			if config.captureEnabled(ctx, ContextCaptureBody) {
+				if reqBody.placeholder == multipartPlaceholder {
+					config.diag.warnOnce("body_multipart", "tag body is used on a multipart request, set LogMultipartBody to log it")
 				}
+				return buf.WriteString(redact(reqBody.String()))
+			}
+		case "response":
+			if resBody != nil && config.captureEnabled(ctx, ContextCaptureResponse) {
+				if p := resBody.omitted(); p != "" && resBody.Size() > 0 {
+					return buf.WriteString(p)
+				}
+				return buf.WriteString(redact(resBody.text(capture.limit(config.MaxResponseLogSize))))
+			}
+		case "response_head":
+			if resBody != nil && config.captureEnabled(ctx, ContextCaptureResponse) {
+				if p := resBody.omitted(); p != "" && resBody.Size() > 0 {
+					return buf.WriteString(p)
+				}
+				head := resBody.body.Bytes()
+				if s, ok := decodeBody(resBody.Header().Get("Content-Encoding"), resBody.body, config.ResponseHeadSize); ok {
+					head = []byte(s)
+				}
+				if len(head) > config.ResponseHeadSize {
+					head = head[:config.ResponseHeadSize]
+				}
+				return buf.WriteString(redact(string(head)))
+			}
+		default:
+			if i := strings.IndexByte(tag, ':'); i >= 0 && !validTagArg(tag[i+1:]) {
 				return 0, nil
-			}); err != nil {
-				return
 			}
+			switch {
+			case strings.HasPrefix(tag, "header:"):
+				return buf.WriteString(config.headerValue(tag[7:], ctx.Request.Header.Get(tag[7:])))
+			case strings.HasPrefix(tag, "header_out:"):
+				return buf.WriteString(config.headerValue(tag[11:], ctx.Writer.Header().Get(tag[11:])))
+			case strings.HasPrefix(tag, "query:"):
+				return buf.Write([]byte(ctx.Query(tag[6:])))
+			case strings.HasPrefix(tag, "param:"):
+				return buf.WriteString(ctx.Param(tag[6:]))
+			case strings.HasPrefix(tag, "context:"):
+				return buf.WriteString(contextValue(ctx, tag[8:]))
+			case strings.HasPrefix(tag, "field:"):
+				return buf.WriteString(config.staticFields[tag[6:]])
+			case strings.HasPrefix(tag, "env:"):
+				return buf.WriteString(config.env[tag[4:]])
+			case strings.HasPrefix(tag, "route_info:"):
+				return buf.WriteString(routeInfo()[tag[11:]])
+			case strings.HasPrefix(tag, "form:"):
+				return buf.Write([]byte(ctx.Request.FormValue(tag[5:])))
+			case strings.HasPrefix(tag, "cookie:"):
+				cookie, err := ctx.Cookie(tag[7:])
+				if err == nil {
+					return buf.WriteString(config.cookieValue(tag[7:], cookie))
+				}
+			default:
+				if f, ok := config.CustomTags[tag]; ok {
+					return buf.WriteString(f(ctx))
+				}
+				config.diag.warnOnce("tag:"+tag, "unknown tag "+tag+" renders empty")
+			}
+		}
+		return 0, nil
+	}
+	if config.encrypter != nil {
+		plain := writeTag
+		writeTag = func(buf *bytes.Buffer, tag string) (int, error) {
+			if !config.encrypted(tag) {
+				return plain(buf, tag)
+			}
+			var tmp bytes.Buffer
+			if _, err := plain(&tmp, tag); err != nil || tmp.Len() == 0 {
+				return 0, err
+			}
+			v, err := config.encrypter.encrypt(tmp.Bytes())
+			if err != nil {
+				return 0, err
+			}
+			return buf.WriteString(v)
+		}
+	}
+	// unescaped holds a tag value before it is escaped by EscapeJSON.
+	var unescaped bytes.Buffer
+	execute := func(buf *bytes.Buffer, t *fasttemplate.Template) error {
+		_, err := t.ExecuteFunc(buf, func(w io.Writer, tag string) (int, error) {
+			if !config.EscapeJSON || !escapedTag(tag) {
+				return writeTag(buf, tag)
+			}
+			unescaped.Reset()
+			if _, err := writeTag(&unescaped, tag); err != nil {
+				return 0, err
+			}
+			n := buf.Len()
+			writeJSONEscaped(buf, unescaped.Bytes())
+			return buf.Len() - n, nil
+		})
+		return err
+	}
+	render := func(buf *bytes.Buffer) error {
+		if config.Fields != nil {
+			return config.encodeFields(buf, writeTag, ctx.Writer.Status(), routeInfo())
+		}
+		if config.fastPath && errInfo == "" {
+			e := defaultEntry{
+				ts:        ts,
+				id:        requestID,
+				remoteIP:  ctx.ClientIP(),
+				host:      ctx.Request.Host,
+				method:    config.method(ctx.Request),
+				uri:       ctx.Request.RequestURI,
+				userAgent: ctx.Request.UserAgent(),
+				status:    statusText(colorer, ctx.Writer.Status()),
+				latency:   stop.Sub(start),
+				bytesIn:   bytesIn(),
+				bytesOut:  bytesOut(),
+			}
+			e.encode(buf, config.EscapeJSON)
+			return nil
+		}
+		return execute(buf, config.template)
+	}
+	if err := render(buf); err != nil {
+		return
+	}
 
-			_, _ = config.Output.Write(buf.Bytes())
-			return
+	if out != nil {
+		config.writeTo(out, buf.Bytes(), config.dropped)
+	} else {
+		config.write(buf.Bytes())
+	}
+	if out == nil && len(config.extraOutputs) > 0 {
+		// Render again only for a different color setting.
+		var other *bytes.Buffer
+		for _, o := range config.extraOutputs {
+			if o.colorer.Enabled() == colorer.Enabled() {
+				config.writeExtra(o, buf.Bytes())
+				continue
+			}
+			if other == nil {
+				other = config.pool.Get().(*bytes.Buffer)
+				other.Reset()
+				defer config.putBuffer(other)
+				main := colorer
+				colorer = o.colorer
+				err := render(other)
+				colorer = main
+				if err != nil {
+					break
+				}
+			}
+			config.writeExtra(o, other.Bytes())
+		}
+	}
+	if config.StoreEntry {
+		ctx.Set(ContextEntry, buf.String())
+	}
+	if config.chain != nil && out == nil {
+		// The chain follows Output, link after a possible second
+		// rendering so prev_hash is the same in both writers.
+		defer config.chain.link(buf.Bytes())
+	}
+	if level == LevelError && config.ErrorOutput != nil && !config.ErrorOutputOnly {
+		ebuf := config.pool.Get().(*bytes.Buffer)
+		ebuf.Reset()
+		defer config.putBuffer(ebuf)
+		colorer = config.errColorer
+		if err := render(ebuf); err == nil {
+			config.writeTo(config.ErrorOutput, ebuf.Bytes(), config.outputFailures)
+		}
+	}
+	if config.shadowTemplate != nil {
+		sbuf := config.pool.Get().(*bytes.Buffer)
+		sbuf.Reset()
+		defer config.putBuffer(sbuf)
+		colorer = config.shadowColorer
+		if err := execute(sbuf, config.shadowTemplate); err != nil || !config.writeTo(config.ShadowOutput, sbuf.Bytes(), nil) {
+			atomic.AddUint64(&m.shadowFailures, 1)
+		}
+	}
+}
+
+// maxPooledBuffer is the capacity above which a buffer is dropped instead
+// of returned to the pool, so one large body does not pin its memory.
+const maxPooledBuffer = 64 << 10
+
+// putBuffer returns buf to the pool unless it grew beyond maxPooledBuffer.
+func (config *LoggerConfig) putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	config.pool.Put(buf)
+}
+
+// newColorer returns a colorer for w honoring DisableColor and ForceColor.
+func (config *LoggerConfig) newColorer(w io.Writer) *color.Color {
+	c := color.New()
+	c.SetOutput(w)
+	switch {
+	case config.DisableColor:
+		c.Disable()
+	case config.ForceColor:
+		c.Enable()
+	}
+	return c
+}
+
+// method returns the method of r, honoring X-HTTP-Method-Override when
+// TrustMethodOverride is set and the header holds a plausible method.
+func (config *LoggerConfig) method(r *http.Request) string {
+	if !config.TrustMethodOverride {
+		return r.Method
+	}
+	m := strings.ToUpper(strings.TrimSpace(r.Header.Get("X-HTTP-Method-Override")))
+	if m == "" || len(m) > 16 {
+		return r.Method
+	}
+	for i := 0; i < len(m); i++ {
+		if m[i] < 'A' || m[i] > 'Z' {
+			return r.Method
+		}
+	}
+	return m
+}
+
+// contextValue formats the gin context value stored under key, or returns
+// an empty string when it is not set.
+func contextValue(ctx *gin.Context, key string) string {
+	v, ok := ctx.Get(key)
+	if !ok || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// newRequestID returns a random (version 4) UUID.
+func newRequestID() string {
+	var b [16]byte
+	_, _ = crand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Entry returns the entry logged for the request when StoreEntry is set,
+// as written to Output: a Format line or the JSON object of Fields.
+func Entry(ctx *gin.Context) (string, bool) {
+	v, ok := ctx.Get(ContextEntry)
+	if !ok {
+		return "", false
+	}
+	s, ok := v.(string)
+	return s, ok
+}
+
+// write writes an entry to Output. Problems with the sink are counted
+// and never propagated into the request.
+func (config *LoggerConfig) write(b []byte) {
+	if config.async != nil {
+		config.writeTo(config.async, b, config.dropped)
+		return
+	}
+	config.writeTo(config.Output, b, config.dropped)
+}
+
+// writeTo writes an entry to w with the same guarantees as write and
+// reports whether it succeeded. Failures are counted in failures when it
+// is not nil.
+func (config *LoggerConfig) writeTo(w io.Writer, b []byte, failures *uint64) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
 		}
+		if !ok && failures != nil {
+			atomic.AddUint64(failures, 1)
+		}
+	}()
+	if w == nil {
+		return false
 	}
+	_, err := w.Write(b)
+	return err == nil
 }
 
 func (w bodyLogWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
+	if w.omitted() == "" {
+		w.body.write(b)
+	}
 	return w.ResponseWriter.Write(b)
 }
+
+func (w bodyLogWriter) WriteString(s string) (int, error) {
+	if w.omitted() == "" {
+		w.body.write([]byte(s))
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// ReadFrom copies r to the response, keeping the bounded capture, for
+// handlers such as httputil.ReverseProxy streaming an upstream response.
+// It uses the ReadFrom of the wrapped writer when it has one.
+func (w bodyLogWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.omitted() == "" {
+		r = io.TeeReader(r, limitedWriter{w.body})
+	}
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	// Hide ReadFrom from io.Copy.
+	return io.Copy(struct{ io.Writer }{w.ResponseWriter}, r)
+}
+
+// chunkingThreshold is the response size above which net/http stops
+// buffering to compute Content-Length and switches to chunked encoding.
+const chunkingThreshold = 2048
+
+// transferEncoding returns how the response body is framed on HTTP/1.1:
+// "chunked" or "content-length", or "none" for a response without body or
+// over another protocol without one of these headers. Without explicit
+// headers it follows net/http, which computes Content-Length for bodies
+// up to chunkingThreshold unless the handlers flushed.
+func transferEncoding(ctx *gin.Context, flushed bool) string {
+	h := ctx.Writer.Header()
+	switch {
+	case strings.Contains(strings.ToLower(h.Get("Transfer-Encoding")), "chunked"):
+		return "chunked"
+	case h.Get("Content-Length") != "":
+		return "content-length"
+	case !ctx.Writer.Written() || ctx.Writer.Size() <= 0 || !ctx.Request.ProtoAtLeast(1, 1) || ctx.Request.ProtoMajor > 1:
+		return "none"
+	case flushed || ctx.Writer.Size() > chunkingThreshold:
+		return "chunked"
+	}
+	return "content-length"
+}
+
+// flushWriter records whether the handlers flushed the response, for the
+// transfer_encoding tag.
+type flushWriter struct {
+	gin.ResponseWriter
+	flushed bool
+}
+
+func (w *flushWriter) Flush() {
+	w.flushed = true
+	w.ResponseWriter.Flush()
+}
+
+// corsAllowed reports whether the response allows the Origin of a cross
+// origin request.
+func corsAllowed(ctx *gin.Context) bool {
+	origin := ctx.Request.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	allowed := ctx.Writer.Header().Get("Access-Control-Allow-Origin")
+	return allowed == "*" || allowed == origin
+}
+
+// text returns the captured response, decompressed according to its
+// Content-Encoding to at most limit bytes.
+func (w bodyLogWriter) text(limit int) string {
+	if s, ok := decodeBody(w.Header().Get("Content-Encoding"), w.body, limit); ok {
+		return s
+	}
+	return w.body.String()
+}
+
+// omitted returns the placeholder of a response body excluded by
+// BodyContentTypes, or "" when it is logged.
+func (w bodyLogWriter) omitted() string {
+	return omittedType(w.types, w.Header().Get("Content-Type"))
+}
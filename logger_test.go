@@ -0,0 +1,666 @@
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// syncBuffer is a bytes.Buffer safe for the async writers.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// Lines returns the lines written so far.
+func (b *syncBuffer) Lines() []string {
+	s := strings.TrimSuffix(b.String(), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// discard is an io.Writer dropping everything.
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
+
+// nopWriter is a reusable http.ResponseWriter dropping everything.
+type nopWriter struct{ h http.Header }
+
+func (w *nopWriter) Header() http.Header {
+	if w.h == nil {
+		w.h = make(http.Header)
+	}
+	return w.h
+}
+
+func (w *nopWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func (w *nopWriter) WriteHeader(int) {}
+
+// newTestRouter returns a router using a middleware built from config,
+// writing to the returned buffer unless config has an Output.
+func newTestRouter(t testing.TB, config LoggerConfig) (*gin.Engine, *syncBuffer, *Middleware) {
+	t.Helper()
+	out := new(syncBuffer)
+	if config.Output == nil && len(config.Outputs) == 0 {
+		config.Output = out
+	}
+	m, err := newMiddleware(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(m.handle)
+	return r, out, m
+}
+
+// serve sends a request to r. header lists name and value pairs.
+func serve(r http.Handler, method, target string, body io.Reader, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// parsePairs parses the key=value pairs of a text side line.
+func parsePairs(line string) map[string]string {
+	pairs := make(map[string]string)
+	for line != "" {
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			break
+		}
+		key, rest := line[:i], line[i+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			// The value ends at the first quote not escaped by a
+			// backslash.
+			end := 1
+			for end < len(rest) && rest[end] != '"' {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(rest) {
+				break
+			}
+			var err error
+			if value, err = strconv.Unquote(rest[:end+1]); err != nil {
+				break
+			}
+			rest = rest[end+1:]
+		} else if j := strings.IndexByte(rest, ' '); j >= 0 {
+			value, rest = rest[:j], rest[j:]
+		} else {
+			value, rest = rest, ""
+		}
+		pairs[key] = value
+		line = strings.TrimPrefix(rest, " ")
+	}
+	return pairs
+}
+
+func benchmarkLoggerWithConfig(b *testing.B, config LoggerConfig, method string, body string) {
+	config.Output = discard{}
+	r := gin.New()
+	r.Use(LoggerWithConfig(config))
+	r.Any("/users/:id", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, `{"id":42,"name":"alice"}`)
+	})
+	w := new(nopWriter)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(method, "/users/42?q=1", strings.NewReader(body))
+		r.ServeHTTP(w, req)
+	}
+}
+
+// BenchmarkLoggerWithConfig tracks the cost of a request with the default
+// config, then with the error and body tags the defaults leave out.
+func BenchmarkLoggerWithConfig(b *testing.B) {
+	b.Run("default", func(b *testing.B) {
+		benchmarkLoggerWithConfig(b, DefaultLoggerConfig, "GET", "")
+	})
+	b.Run("error", func(b *testing.B) {
+		config := DefaultLoggerConfig
+		config.Format = `{"status":${status},"error":"${error}"}` + "\n"
+		benchmarkLoggerWithConfig(b, config, "GET", "")
+	})
+	b.Run("bodies", func(b *testing.B) {
+		config := DefaultLoggerConfig
+		config.Format = `{"body":"${body}","response":"${response}"}` + "\n"
+		benchmarkLoggerWithConfig(b, config, "POST", `{"user":"alice","password":"hunter2"}`)
+	})
+}
+
+func TestPutBuffer(t *testing.T) {
+	m, err := newMiddleware(LoggerConfig{Output: discard{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &m.config
+	buf := config.pool.Get().(*bytes.Buffer)
+	if buf.Len() != 0 || buf.Cap() < 256 {
+		t.Errorf("pooled buffer has length %d, capacity %d", buf.Len(), buf.Cap())
+	}
+	big := bytes.NewBuffer(make([]byte, 0, maxPooledBuffer+1))
+	config.putBuffer(big)
+	for i := 0; i < 10; i++ {
+		if config.pool.Get().(*bytes.Buffer) == big {
+			t.Fatal("oversized buffer returned to the pool")
+		}
+	}
+}
+
+// chaosWriter forwards to a writer swapped while requests are served.
+type chaosWriter struct{ w atomic.Value }
+
+func (c *chaosWriter) Write(p []byte) (int, error) {
+	return c.w.Load().(*writerBox).w.Write(p)
+}
+
+type writerBox struct{ w io.Writer }
+
+// TestOutputChaos swaps Output between a nil buffer, a closed pipe and a
+// working buffer under concurrent requests: every request must succeed
+// and every entry must be written or counted as dropped. Run with -race.
+func TestOutputChaos(t *testing.T) {
+	pr, pw := io.Pipe()
+	pr.Close()
+	good := new(syncBuffer)
+	writers := []io.Writer{(*bytes.Buffer)(nil), pw, good}
+	for _, async := range []bool{false, true} {
+		t.Run("async="+strconv.FormatBool(async), func(t *testing.T) {
+			chaos := new(chaosWriter)
+			chaos.w.Store(&writerBox{good})
+			before := len(good.Lines())
+			r, _, m := newTestRouter(t, LoggerConfig{Format: "${status}\n", Output: chaos, Async: async})
+			var swaps uint64
+			r.GET("/", func(ctx *gin.Context) {
+				// The writer changes between requests and while other
+				// requests are writing.
+				i := atomic.AddUint64(&swaps, 1)
+				chaos.w.Store(&writerBox{writers[i%uint64(len(writers))]})
+				ctx.Status(http.StatusNoContent)
+			})
+			const workers, requests = 8, 200
+			var wg sync.WaitGroup
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < requests; j++ {
+						if w := serve(r, "GET", "/", nil); w.Code != http.StatusNoContent {
+							t.Errorf("status = %d", w.Code)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			m.Close()
+			written := uint64(len(good.Lines()) - before)
+			if dropped := m.Stats().Dropped; written+dropped != workers*requests || dropped == 0 {
+				t.Errorf("%d entries written and %d dropped, want %d in total with some dropped", written, dropped, workers*requests)
+			}
+		})
+	}
+}
+
+func TestBytesAndID(t *testing.T) {
+	config := DefaultLoggerConfig
+	config.Output = nil
+	r, out, _ := newTestRouter(t, config)
+	r.POST("/echo", func(ctx *gin.Context) {
+		b, _ := ioutil.ReadAll(ctx.Request.Body)
+		ctx.String(http.StatusOK, "%s!", b)
+	})
+	serve(r, "POST", "/echo", strings.NewReader("hello"), "X-Request-ID", "req-1")
+	serve(r, "POST", "/echo", strings.NewReader("hi"))
+	lines := out.Lines()
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	for i, want := range []struct {
+		id      string
+		in, out float64
+	}{{"req-1", 5, 6}, {"", 2, 3}} {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatalf("line %d is not JSON: %v", i, err)
+		}
+		id, ok := entry["id"].(string)
+		if !ok || want.id != "" && id != want.id || want.id == "" && len(id) != 36 {
+			t.Errorf("line %d: id = %#v, want %q or a generated UUID", i, entry["id"], want.id)
+		}
+		if in, ok := entry["bytes_in"].(float64); !ok || in != want.in {
+			t.Errorf("line %d: bytes_in = %#v, want %v", i, entry["bytes_in"], want.in)
+		}
+		if n, ok := entry["bytes_out"].(float64); !ok || n != want.out {
+			t.Errorf("line %d: bytes_out = %#v, want %v", i, entry["bytes_out"], want.out)
+		}
+	}
+}
+
+func TestHeapAlloc(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${heap_alloc}\n", LogMemStats: true})
+	r.GET("/fail", func(ctx *gin.Context) { ctx.Status(http.StatusInternalServerError) })
+	r.GET("/ok", func(ctx *gin.Context) {})
+	serve(r, "GET", "/fail", nil)
+	serve(r, "GET", "/ok", nil)
+	lines := out.Lines()
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	if n, err := strconv.ParseUint(lines[0], 10, 64); err != nil || n == 0 {
+		t.Errorf("heap_alloc on an error line = %q, want a nonzero value", lines[0])
+	}
+	if lines[1] != "-1" {
+		t.Errorf("heap_alloc on a 200 line = %q, want -1", lines[1])
+	}
+}
+
+func TestParamsObject(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Fields: map[string]string{"params": "params_object"}})
+	r.GET("/orgs/:org/users/:id/*file", func(ctx *gin.Context) {})
+	r.GET("/", func(ctx *gin.Context) {})
+	serve(r, "GET", "/orgs/acme/users/42/docs/a%22b.txt", nil)
+	serve(r, "GET", "/", nil)
+	want := []string{
+		`{"params":{"file":"/docs/a\"b.txt","id":"42","org":"acme"}}`,
+		`{"params":{}}`,
+	}
+	if got := out.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+}
+
+func TestDisableColor(t *testing.T) {
+	for _, tc := range []struct {
+		config  LoggerConfig
+		colored bool
+	}{
+		{LoggerConfig{DisableColor: true}, false},
+		{LoggerConfig{DisableColor: true, ForceColor: true}, false},
+		{LoggerConfig{ForceColor: true}, true},
+	} {
+		r, out, _ := newTestRouter(t, tc.config)
+		r.GET("/", func(ctx *gin.Context) {})
+		serve(r, "GET", "/", nil)
+		line := out.String()
+		if colored := strings.Contains(line, "\x1b["); colored != tc.colored {
+			t.Errorf("DisableColor %v, ForceColor %v: colored = %v", tc.config.DisableColor, tc.config.ForceColor, colored)
+		}
+		if tc.colored {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("entry is not JSON: %v\n%s", err, line)
+		}
+		if entry["status"] != 200.0 || !strings.Contains(line, `"status":200,`) {
+			t.Errorf("status = %#v, want the bare number 200", entry["status"])
+		}
+	}
+}
+
+func TestTimeTags(t *testing.T) {
+	for _, atStart := range []bool{false, true} {
+		// The clock advances by a second each time it is read, so the
+		// start and the completion of a request differ.
+		clock := steppingClock(time.Second)
+		r, out, _ := newTestRouter(t, LoggerConfig{
+			Format:      "${time_rfc3339_nano} ${time_unix_nano} ${time_unix}\n",
+			TimeAtStart: atStart,
+			now:         clock,
+		})
+		r.GET("/", func(ctx *gin.Context) {})
+		serve(r, "GET", "/", nil)
+		want := time.Date(2020, 1, 2, 3, 4, 7, 600, time.FixedZone("CET", 3600))
+		if atStart {
+			want = want.Add(-time.Second)
+		}
+		fields := strings.Fields(out.String())
+		if len(fields) != 3 {
+			t.Fatalf("entry = %q", out)
+		}
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil || !ts.Equal(want) {
+			t.Errorf("TimeAtStart %v: time_rfc3339_nano = %s, want %s", atStart, fields[0], want.Format(time.RFC3339Nano))
+		}
+		if fields[1] != strconv.FormatInt(want.UnixNano(), 10) || fields[2] != strconv.FormatInt(want.Unix(), 10) {
+			t.Errorf("TimeAtStart %v: time tags render different instants: %q", atStart, out)
+		}
+	}
+}
+
+func TestMethodOverride(t *testing.T) {
+	for _, tc := range []struct {
+		trust    bool
+		override string
+		want     string
+	}{
+		{true, "DELETE", "DELETE POST"},
+		{true, " delete ", "DELETE POST"},
+		{true, "DEL ETE", "POST POST"},
+		{true, "", "POST POST"},
+		{false, "DELETE", "POST POST"},
+	} {
+		r, out, _ := newTestRouter(t, LoggerConfig{Format: "${method} ${raw_method}\n", TrustMethodOverride: tc.trust})
+		r.POST("/users/:id", func(ctx *gin.Context) {})
+		serve(r, "POST", "/users/1", nil, "X-HTTP-Method-Override", tc.override)
+		if got := strings.TrimSuffix(out.String(), "\n"); got != tc.want {
+			t.Errorf("trust %v, override %q: entry = %q, want %q", tc.trust, tc.override, got, tc.want)
+		}
+	}
+}
+
+var allocSink []byte
+
+func TestAllocBytes(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${alloc_bytes}\n", LogAllocs: true})
+	r.GET("/", func(ctx *gin.Context) { allocSink = make([]byte, 1<<20) })
+	serve(r, "GET", "/", nil)
+	if n, err := strconv.ParseUint(strings.TrimSuffix(out.String(), "\n"), 10, 64); err != nil || n < 1<<20 {
+		t.Errorf("alloc_bytes = %q, want at least 1MiB", out)
+	}
+
+	r, out, _ = newTestRouter(t, LoggerConfig{Format: "${alloc_bytes}\n"})
+	r.GET("/", func(ctx *gin.Context) {})
+	serve(r, "GET", "/", nil)
+	if out.String() != "-1\n" {
+		t.Errorf("alloc_bytes without LogAllocs = %q, want -1", out)
+	}
+}
+
+// retainingWriter keeps the slices it is given without copying them,
+// which is only safe behind the Async queue.
+type retainingWriter struct {
+	mu    sync.Mutex
+	lines [][]byte
+}
+
+func (w *retainingWriter) Write(p []byte) (int, error) {
+	time.Sleep(10 * time.Microsecond)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lines = append(w.lines, p)
+	return len(p), nil
+}
+
+// TestBufferReuse checks, under -race, that pooled buffers are not reused
+// while a writer still holds their bytes.
+func TestBufferReuse(t *testing.T) {
+	out := new(retainingWriter)
+	r, _, m := newTestRouter(t, LoggerConfig{Output: out, Format: "${path} ${response}\n", Async: true, BlockOnFull: true})
+	r.GET("/:n", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, strings.Repeat(ctx.Param("n"), 100))
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			serve(r, "GET", "/"+strconv.Itoa(i), nil)
+		}(i)
+	}
+	wg.Wait()
+	m.Close()
+	if len(out.lines) != 200 {
+		t.Fatalf("got %d lines, want 200", len(out.lines))
+	}
+	for _, line := range out.lines {
+		fields := strings.Fields(string(line))
+		if len(fields) != 2 || fields[1] != strings.Repeat(fields[0][1:], 100) {
+			t.Errorf("corrupted line %q", line)
+		}
+	}
+}
+
+func TestNoColorForBuffer(t *testing.T) {
+	out := new(bytes.Buffer)
+	r, _, _ := newTestRouter(t, LoggerConfig{Output: out, Format: "${status} ${latency_human}\n", ColorLatency: true, SlowThreshold: time.Second})
+	r.GET("/", func(ctx *gin.Context) {})
+	serve(r, "GET", "/", nil)
+	if strings.Contains(out.String(), "\x1b") || !strings.HasPrefix(out.String(), "200 ") {
+		t.Errorf("entry = %q, want a bare 200 without escape sequences", out)
+	}
+}
+
+func TestMatched(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${path} ${status} ${matched}\n"})
+	r.GET("/users/:id", func(ctx *gin.Context) {})
+	serve(r, "GET", "/users/1", nil)
+	serve(r, "GET", "/nowhere", nil)
+	want := []string{"/users/1 200 true", "/nowhere 404 false"}
+	if got := out.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+}
+
+func TestGenerateRequestID(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${id}\n", GenerateRequestID: true, RequestIDHeader: "X-Trace"})
+	var seen []string
+	r.GET("/", func(ctx *gin.Context) { seen = append(seen, ctx.GetString(ContextRequestID)) })
+	w1 := serve(r, "GET", "/", nil)
+	w2 := serve(r, "GET", "/", nil, "X-Trace", "given")
+	lines := out.Lines()
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	id := lines[0]
+	if len(id) != 36 || id[14] != '4' {
+		t.Errorf("generated id = %q, want a UUID v4", id)
+	}
+	if w1.Header().Get("X-Trace") != id || seen[0] != id {
+		t.Errorf("response header %q and context %q, want the logged id %q", w1.Header().Get("X-Trace"), seen[0], id)
+	}
+	if lines[1] != "given" || w2.Header().Get("X-Trace") != "given" || seen[1] != "given" {
+		t.Errorf("request with an id: logged %q, header %q, context %q", lines[1], w2.Header().Get("X-Trace"), seen[1])
+	}
+
+	r, out, _ = newTestRouter(t, LoggerConfig{Format: "${id}\n"})
+	r.GET("/", func(ctx *gin.Context) {})
+	if w := serve(r, "GET", "/", nil); out.String() != "\n" || w.Header().Get("X-Request-ID") != "" {
+		t.Errorf("id generated without GenerateRequestID: %q", out)
+	}
+}
+
+func TestErrorOutput(t *testing.T) {
+	for _, only := range []bool{false, true} {
+		errOut := new(syncBuffer)
+		r, out, _ := newTestRouter(t, LoggerConfig{Format: "${path} ${status}\n", ErrorOutput: errOut, ErrorOutputOnly: only})
+		r.GET("/ok", func(ctx *gin.Context) {})
+		r.GET("/fail", func(ctx *gin.Context) { ctx.Status(http.StatusInternalServerError) })
+		serve(r, "GET", "/ok", nil)
+		serve(r, "GET", "/fail", nil)
+		want := []string{"/ok 200", "/fail 500"}
+		if only {
+			want = want[:1]
+		}
+		if got := out.Lines(); !reflect.DeepEqual(got, want) {
+			t.Errorf("ErrorOutputOnly %v: Output = %q, want %q", only, got, want)
+		}
+		if got := errOut.Lines(); !reflect.DeepEqual(got, []string{"/fail 500"}) {
+			t.Errorf("ErrorOutputOnly %v: ErrorOutput = %q, want the error entry", only, got)
+		}
+	}
+}
+
+func TestStoreEntry(t *testing.T) {
+	m, err := newMiddleware(LoggerConfig{Format: "${path} ${status}\n", Output: discard{}, StoreEntry: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry string
+	var stored bool
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) {
+		ctx.Next()
+		entry, stored = Entry(ctx)
+	})
+	r.Use(m.handle)
+	r.GET("/audit", func(ctx *gin.Context) { ctx.Status(http.StatusCreated) })
+	serve(r, "GET", "/audit", nil)
+	if !stored || entry != "/audit 201\n" {
+		t.Errorf("Entry = %q, %v, want the logged line", entry, stored)
+	}
+
+	m, err = newMiddleware(LoggerConfig{Format: "${path}\n", Output: discard{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = gin.New()
+	r.Use(func(ctx *gin.Context) {
+		ctx.Next()
+		entry, stored = Entry(ctx)
+	})
+	r.Use(m.handle)
+	r.GET("/audit", func(ctx *gin.Context) {})
+	serve(r, "GET", "/audit", nil)
+	if stored {
+		t.Errorf("Entry without StoreEntry = %q", entry)
+	}
+}
+
+func TestStatusExplicit(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${path} ${status} ${status_explicit}\n"})
+	r.GET("/nothing", func(ctx *gin.Context) {})
+	r.GET("/status", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+	r.GET("/body", func(ctx *gin.Context) { ctx.String(http.StatusOK, "ok") })
+	for _, p := range []string{"/nothing", "/status", "/body"} {
+		if w := serve(r, "GET", p, nil); p == "/nothing" && w.Code != http.StatusOK {
+			t.Errorf("client got %d for a handler writing nothing", w.Code)
+		}
+	}
+	want := []string{"/nothing 200 false", "/status 204 true", "/body 200 true"}
+	if got := out.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${method} ${origin} ${cors_allowed}\n"})
+	cors := func(ctx *gin.Context) {
+		if origin := ctx.GetHeader("Origin"); origin == "https://app.example" {
+			ctx.Header("Access-Control-Allow-Origin", origin)
+		}
+		if ctx.Request.Method == http.MethodOptions {
+			ctx.AbortWithStatus(http.StatusNoContent)
+		}
+	}
+	r.Use(cors)
+	r.OPTIONS("/api", func(ctx *gin.Context) {})
+	r.GET("/api", func(ctx *gin.Context) {})
+	r.GET("/public", func(ctx *gin.Context) { ctx.Header("Access-Control-Allow-Origin", "*") })
+	serve(r, "OPTIONS", "/api", nil, "Origin", "https://app.example", "Access-Control-Request-Method", "PUT")
+	serve(r, "GET", "/api", nil, "Origin", "https://app.example")
+	serve(r, "GET", "/api", nil, "Origin", "https://evil.example")
+	serve(r, "GET", "/public", nil, "Origin", "https://evil.example")
+	serve(r, "GET", "/api", nil)
+	want := []string{
+		"OPTIONS https://app.example true",
+		"GET https://app.example true",
+		"GET https://evil.example false",
+		"GET https://evil.example true",
+		"GET  false",
+	}
+	if got := out.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+}
+
+func TestTransferEncoding(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${path} ${transfer_encoding}\n"})
+	r.GET("/small", func(ctx *gin.Context) { ctx.String(http.StatusOK, "hello") })
+	r.GET("/large", func(ctx *gin.Context) { ctx.String(http.StatusOK, strings.Repeat("x", 4096)) })
+	r.GET("/flushed", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "a")
+		ctx.Writer.Flush()
+		ctx.String(http.StatusOK, "b")
+	})
+	r.GET("/length", func(ctx *gin.Context) {
+		ctx.Header("Content-Length", "4096")
+		ctx.String(http.StatusOK, strings.Repeat("x", 4096))
+	})
+	r.GET("/empty", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+	server := httptest.NewServer(r)
+	defer server.Close()
+	for _, p := range []string{"/small", "/large", "/flushed", "/length", "/empty"} {
+		resp, err := http.Get(server.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		// Check the logged framing against what the client received.
+		want := "content-length"
+		switch {
+		case len(resp.TransferEncoding) > 0:
+			want = resp.TransferEncoding[0]
+		case resp.ContentLength <= 0:
+			want = "none"
+		}
+		lines := out.Lines()
+		if got := lines[len(lines)-1]; got != p+" "+want {
+			t.Errorf("entry = %q, want %q", got, p+" "+want)
+		}
+	}
+}
+
+func TestAppID(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: `{"status":${status},"app_id":"${app_id}"}` + "\n", DiagnosticsOutput: new(syncBuffer)})
+	r.GET("/app", func(ctx *gin.Context) { ctx.Set(ContextAppID, "svc-42") })
+	r.GET("/none", func(ctx *gin.Context) {})
+	r.GET("/error", func(ctx *gin.Context) { ctx.Set(ContextError, "failed") })
+	r.GET("/number", func(ctx *gin.Context) { ctx.Set(ContextAppID, 42) })
+	for _, path := range []string{"/app", "/none", "/error", "/number"} {
+		if w := serve(r, "GET", path, nil); w.Code != http.StatusOK {
+			t.Errorf("%s: status %d, want 200", path, w.Code)
+		}
+	}
+	want := []string{
+		`{"status":200,"app_id":"svc-42"}`,
+		`{"status":200,"app_id":""}`,
+		`{"status":200,"app_id":""}`,
+		`{"status":200,"app_id":"42"}`,
+	}
+	if got := out.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package glog
+
+import (
+	"context"
+	crand "crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+)
+
+// processSinkSecret is the SinkHeader value of the requests sent through
+// SinkTransport when SinkSecret is not set. It is generated for the
+// process, so a client cannot guess it to keep its requests out of the log.
+var processSinkSecret = func() string {
+	var b [16]byte
+	_, _ = crand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}()
+
+// sinkKey is the context key set by SinkContext.
+type sinkKey struct{}
+
+// SinkContext returns a copy of parent marking the requests using it as
+// sent by a sink. With LoopProtection they are not logged. Use it for the
+// requests a writer dispatches into the server in process, without going
+// through SinkTransport:
+//
+//	req = req.WithContext(glog.SinkContext(req.Context()))
+//	engine.ServeHTTP(w, req)
+func SinkContext(parent context.Context) context.Context {
+	return context.WithValue(parent, sinkKey{}, true)
+}
+
+// sinkTransport marks the requests of an HTTP based Output with SinkHeader.
+type sinkTransport struct {
+	rt             http.RoundTripper
+	header, secret string
+}
+
+// SinkTransport wraps rt, http.DefaultTransport when nil, for the client of
+// an HTTP based Output such as a log shipper. With LoopProtection its
+// requests carry SinkHeader set to SinkSecret, so when they reach a server
+// logged with the same secret they are not logged in turn, which would
+// write again and loop. Without LoopProtection rt is returned as is.
+//
+//	client := &http.Client{Transport: m.SinkTransport(nil)}
+func (m *Middleware) SinkTransport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	if !m.config.LoopProtection {
+		return rt
+	}
+	return sinkTransport{rt: rt, header: m.config.SinkHeader, secret: m.config.SinkSecret}
+}
+
+func (t sinkTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request.
+	r = r.Clone(r.Context())
+	r.Header.Set(t.header, t.secret)
+	return t.rt.RoundTrip(r)
+}
+
+// fromSink reports whether r was sent by SinkTransport or marked with
+// SinkContext.
+func (config *LoggerConfig) fromSink(r *http.Request) bool {
+	if marked, _ := r.Context().Value(sinkKey{}).(bool); marked {
+		return true
+	}
+	v := r.Header.Get(config.SinkHeader)
+	return v != "" && subtle.ConstantTimeCompare([]byte(v), []byte(config.SinkSecret)) == 1
+}
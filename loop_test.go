@@ -0,0 +1,183 @@
+package glog
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// httpSink posts every entry to url, like a log shipper.
+type httpSink struct {
+	mu     sync.Mutex
+	client *http.Client
+	url    string
+}
+
+func (s *httpSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	client, url := s.client, s.url
+	s.mu.Unlock()
+	res, err := client.Post(url, "text/plain", bytes.NewReader(p))
+	if err != nil {
+		return 0, err
+	}
+	ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	return len(p), nil
+}
+
+func TestLoopProtection(t *testing.T) {
+	var requests, ingested int64
+	sink := new(httpSink)
+	r, _, m := newTestRouter(t, LoggerConfig{
+		Format:         "${method} ${path}\n",
+		Output:         sink,
+		LoopProtection: true,
+	})
+	r.Use(func(ctx *gin.Context) {
+		atomic.AddInt64(&requests, 1)
+	})
+	r.POST("/ingest", func(ctx *gin.Context) {
+		atomic.AddInt64(&ingested, 1)
+	})
+	r.GET("/", func(ctx *gin.Context) {})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	sink.mu.Lock()
+	sink.client = &http.Client{Transport: m.SinkTransport(nil), Timeout: 5 * time.Second}
+	sink.url = srv.URL + "/ingest"
+	sink.mu.Unlock()
+
+	res, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt64(&ingested); n != 1 {
+		t.Errorf("sink received %d entries, want only the one of GET /", n)
+	}
+	if n := atomic.LoadInt64(&requests); n != 2 {
+		t.Errorf("server handled %d requests, want GET / and its entry", n)
+	}
+}
+
+func TestLoopProtectionSecret(t *testing.T) {
+	r, out, m := newTestRouter(t, LoggerConfig{Format: "${path}\n", LoopProtection: true})
+	r.GET("/", func(ctx *gin.Context) {})
+	serve(r, "GET", "/", nil, "X-Glog-Sink", "1")
+	serve(r, "GET", "/", nil, "X-Glog-Sink", processSinkSecret[:len(processSinkSecret)-1])
+	if got := out.Lines(); len(got) != 2 {
+		t.Errorf("entries = %q, want requests with a wrong marker logged", got)
+	}
+	var marked *http.Request
+	rt := m.SinkTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		marked = r
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	rt.RoundTrip(req)
+	if req.Header.Get("X-Glog-Sink") != "" {
+		t.Error("SinkTransport modified the request")
+	}
+	r.ServeHTTP(httptest.NewRecorder(), marked)
+	if got := out.Lines(); len(got) != 2 {
+		t.Errorf("entries = %q, request of SinkTransport logged", got)
+	}
+
+	// Without LoopProtection nothing is marked nor skipped.
+	r, out, m = newTestRouter(t, LoggerConfig{Format: "${path}\n"})
+	r.GET("/", func(ctx *gin.Context) {})
+	if _, ok := m.SinkTransport(nil).(sinkTransport); ok {
+		t.Error("SinkTransport marks requests without LoopProtection")
+	}
+	serve(r, "GET", "/", nil, "X-Glog-Sink", processSinkSecret)
+	if got := out.Lines(); len(got) != 1 {
+		t.Errorf("entries = %q", got)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// dispatchWriter serves a request on the engine for every entry, on the
+// writing goroutine, marked with SinkContext.
+type dispatchWriter struct {
+	engine *gin.Engine
+	depth  int
+}
+
+func (w *dispatchWriter) Write(p []byte) (int, error) {
+	w.depth++
+	if w.depth > 10 {
+		return 0, errors.New("recursion")
+	}
+	req := httptest.NewRequest("POST", "/ingest", bytes.NewReader(p))
+	req = req.WithContext(SinkContext(req.Context()))
+	w.engine.ServeHTTP(httptest.NewRecorder(), req)
+	return len(p), nil
+}
+
+func TestLoopProtectionReentrancy(t *testing.T) {
+	w := new(dispatchWriter)
+	r, _, m := newTestRouter(t, LoggerConfig{Format: "${path}\n", Output: w, LoopProtection: true})
+	w.engine = r
+	r.POST("/ingest", func(ctx *gin.Context) {})
+	r.GET("/", func(ctx *gin.Context) {})
+	serve(r, "GET", "/", nil)
+	if w.depth != 1 {
+		t.Errorf("writer called %d times, want once", w.depth)
+	}
+	if s := m.Stats(); s.Dropped != 0 {
+		t.Errorf("stats %+v", s)
+	}
+
+	// Without LoopProtection the marked requests are logged.
+	w = new(dispatchWriter)
+	r, _, _ = newTestRouter(t, LoggerConfig{Format: "${path}\n", Output: w})
+	w.engine = r
+	r.POST("/ingest", func(ctx *gin.Context) {})
+	serve(r, "POST", "/ingest", nil)
+	if w.depth != 11 {
+		t.Errorf("writer called %d times, want the recursion up to its limit", w.depth)
+	}
+}
+
+func TestSinkSecret(t *testing.T) {
+	replica := func(secret string) (*gin.Engine, *syncBuffer, *Middleware) {
+		r, out, m := newTestRouter(t, LoggerConfig{Format: "${path}\n", LoopProtection: true, SinkSecret: secret})
+		r.GET("/", func(ctx *gin.Context) {})
+		return r, out, m
+	}
+	_, _, a := replica("shared")
+	b, bOut, _ := replica("shared")
+	c, cOut, _ := replica("")
+	var marked *http.Request
+	rt := a.SinkTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		marked = r
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	rt.RoundTrip(httptest.NewRequest("GET", "/", nil))
+	if v := marked.Header.Get("X-Glog-Sink"); v != "shared" {
+		t.Fatalf("sink header %q, want the configured secret", v)
+	}
+	b.ServeHTTP(httptest.NewRecorder(), marked)
+	c.ServeHTTP(httptest.NewRecorder(), marked)
+	if bOut.String() != "" {
+		t.Errorf("replica with the same secret logged %q", bOut)
+	}
+	if cOut.String() != "/\n" {
+		t.Errorf("replica with another secret logged %q, want the request", cOut)
+	}
+}
@@ -0,0 +1,82 @@
+package glog
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// maskedValue is written in place of secret values.
+const maskedValue = "***"
+
+// maskSecret hides a secret value.
+func maskSecret(v string) string {
+	if v == "" {
+		return ""
+	}
+	return maskedValue
+}
+
+// maskCredentials hides the credentials of an Authorization value, keeping
+// only the scheme of values like "Bearer <token>".
+func maskCredentials(v string) string {
+	if i := strings.IndexByte(v, ' '); i > 0 {
+		return v[:i] + " " + maskedValue
+	}
+	return maskSecret(v)
+}
+
+// headerValue returns the value of header name as it may be logged. Every
+// tag logging request or response headers must go through it. Only the
+// Authorization headers keep their scheme; other masked headers, e.g.
+// Cookie and Set-Cookie, are hidden entirely.
+func (config *LoggerConfig) headerValue(name, value string) string {
+	lower := strings.ToLower(name)
+	if _, ok := config.maskedHeaders[lower]; !ok {
+		return value
+	}
+	if lower == "authorization" || lower == "proxy-authorization" {
+		return maskCredentials(value)
+	}
+	return maskSecret(value)
+}
+
+// headersJSON returns h as a JSON object, masked like headerValue and
+// without ExcludedHeaders. Headers with one value map to a string, others
+// to an array.
+func (config *LoggerConfig) headersJSON(h http.Header) []byte {
+	obj := make(map[string]interface{}, len(h))
+	for name, values := range h {
+		if _, ok := config.excludedHeaders[strings.ToLower(name)]; ok {
+			continue
+		}
+		masked := make([]string, len(values))
+		for i, v := range values {
+			masked[i] = config.headerValue(name, v)
+		}
+		if len(masked) == 1 {
+			obj[name] = masked[0]
+		} else {
+			obj[name] = masked
+		}
+	}
+	b, _ := json.Marshal(obj)
+	return b
+}
+
+// cookieValue returns the value of cookie name as it may be logged.
+func (config *LoggerConfig) cookieValue(name, value string) string {
+	if _, ok := config.maskedCookies[strings.ToLower(name)]; ok {
+		return maskSecret(value)
+	}
+	return value
+}
+
+// lowerSet returns the lowercased names as a set.
+func lowerSet(names []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		set[strings.ToLower(n)] = struct{}{}
+	}
+	return set
+}
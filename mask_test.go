@@ -0,0 +1,86 @@
+package glog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaskedHeaders(t *testing.T) {
+	header := []string{"Authorization", "Bearer s3cret", "Cookie", "session=abc", "X-Api-Key", "k", "X-Client", "web"}
+	for _, tc := range []struct {
+		masked []string
+		want   string
+	}{
+		{nil, "Bearer *** *** k web"},
+		{[]string{"x-api-key"}, "Bearer s3cret session=abc *** web"},
+		{[]string{}, "Bearer s3cret session=abc k web"},
+	} {
+		r, out, _ := newTestRouter(t, LoggerConfig{
+			Format:        "${header:Authorization} ${header:Cookie} ${header:X-Api-Key} ${header:X-Client}\n",
+			MaskedHeaders: tc.masked,
+		})
+		r.GET("/", func(ctx *gin.Context) {})
+		serve(r, "GET", "/", nil, header...)
+		if got := out.Lines(); !reflect.DeepEqual(got, []string{tc.want}) {
+			t.Errorf("MaskedHeaders %q: entry = %q, want %q", tc.masked, got, tc.want)
+		}
+	}
+}
+
+func TestRequestHeadersMasked(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${request_headers}\n", ExcludedHeaders: []string{"accept"}})
+	r.GET("/", func(ctx *gin.Context) {})
+	serve(r, "GET", "/", nil, "Authorization", "Basic dXNlcg==", "Accept", "*/*", "X-Client", "web")
+	if want := `{"Authorization":"Basic ***","X-Client":"web"}` + "\n"; out.String() != want {
+		t.Errorf("request_headers = %q, want %q", out, want)
+	}
+}
+
+func TestCookieHeadersMasked(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format:        "${header:Cookie} ${header:Proxy-Authorization} ${request_headers} ${response_headers}\n",
+		MaskedHeaders: []string{"Cookie", "Set-Cookie", "Proxy-Authorization"},
+	})
+	r.GET("/", func(ctx *gin.Context) {
+		ctx.Header("Set-Cookie", "sid=SERVERSECRET; Path=/")
+	})
+	serve(r, "GET", "/", nil, "Cookie", "session=SECRET; theme=dark", "Proxy-Authorization", "Basic PROXYSECRET")
+	line := out.String()
+	for _, secret := range []string{"SECRET", "session", "sid", "theme"} {
+		if strings.Contains(line, secret) {
+			t.Errorf("entry leaks %q: %s", secret, line)
+		}
+	}
+	if want := "*** Basic *** "; !strings.HasPrefix(line, want) {
+		t.Errorf("entry = %q, want the prefix %q", line, want)
+	}
+	if !strings.Contains(line, `"Set-Cookie":"***"`) {
+		t.Errorf("response_headers of %q: Set-Cookie not masked", line)
+	}
+}
+
+func TestCookieTags(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format:        "${cookie_count} ${cookies}\n",
+		MaskedHeaders: []string{},
+		MaskedCookies: []string{"session"},
+	})
+	r.GET("/", func(ctx *gin.Context) {})
+	for _, cookie := range []string{"", "session=SECRET1", "session=SECRET1; theme=SECRET2; lang=SECRET3", "bad cookie; ok=SECRET4"} {
+		if cookie == "" {
+			serve(r, "GET", "/", nil)
+			continue
+		}
+		serve(r, "GET", "/", nil, "Cookie", cookie)
+	}
+	want := []string{"0 ", "1 session", "3 session,theme,lang", "1 ok"}
+	if got := out.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+	if strings.Contains(out.String(), "SECRET") {
+		t.Errorf("cookie value logged: %q", out)
+	}
+}
@@ -0,0 +1,206 @@
+package glog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Option configures a Logger middleware built by New.
+type Option func(*LoggerConfig) error
+
+// New returns a Logger middleware configured by opts on top of
+// DefaultLoggerConfig. Options are validated eagerly, the first invalid
+// one, conflicting options such as WithFormat and WithPreset, or an
+// invalid tag argument in the format is returned as error.
+func New(opts ...Option) (gin.HandlerFunc, *Middleware, error) {
+	config := DefaultLoggerConfig
+	for _, opt := range opts {
+		if err := opt(&config); err != nil {
+			return nil, nil, err
+		}
+	}
+	m, err := newMiddleware(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m.handle, m, nil
+}
+
+// Config returns the effective config of the middleware.
+func (m *Middleware) Config() LoggerConfig {
+	return m.config
+}
+
+// The presets of WithPreset.
+const (
+	// PresetJSON is the JSON format of DefaultLoggerConfig.
+	PresetJSON = "json"
+	// PresetConsole is the format of ConsoleLogger.
+	PresetConsole = "console"
+	// PresetStructured encodes DefaultStructuredFields, see
+	// LoggerConfig.Structured.
+	PresetStructured = "structured"
+)
+
+// claimFormat records that option sets the format. Only one option may,
+// a second one is reported as a conflict.
+func (config *LoggerConfig) claimFormat(option string) error {
+	if config.formatOption != "" {
+		return fmt.Errorf("glog: %s conflicts with %s", option, config.formatOption)
+	}
+	config.formatOption = option
+	return nil
+}
+
+// WithFormat sets the log format, see LoggerConfig.Format. It conflicts
+// with WithPreset.
+func WithFormat(format string) Option {
+	return func(config *LoggerConfig) error {
+		if format == "" {
+			return errors.New("glog: empty format")
+		}
+		if err := config.claimFormat("WithFormat"); err != nil {
+			return err
+		}
+		config.Format = format
+		return nil
+	}
+}
+
+// WithPreset sets the format from a preset: PresetJSON, PresetConsole or
+// PresetStructured. It conflicts with WithFormat. PresetConsole keeps a
+// layout set by WithCustomTimeFormat.
+func WithPreset(name string) Option {
+	return func(config *LoggerConfig) error {
+		if name != PresetJSON && name != PresetConsole && name != PresetStructured {
+			return fmt.Errorf("glog: unknown preset %q", name)
+		}
+		if err := config.claimFormat(fmt.Sprintf("WithPreset(%q)", name)); err != nil {
+			return err
+		}
+		switch name {
+		case PresetJSON:
+			config.Format = DefaultLoggerConfig.Format
+		case PresetConsole:
+			console := consoleConfig()
+			config.Format = console.Format
+			config.EscapeJSON = console.EscapeJSON
+			if config.CustomTimeFormat == DefaultLoggerConfig.CustomTimeFormat {
+				config.CustomTimeFormat = console.CustomTimeFormat
+			}
+		case PresetStructured:
+			config.Structured = true
+		}
+		return nil
+	}
+}
+
+// WithCustomTimeFormat sets the layout of the time_custom tag.
+func WithCustomTimeFormat(layout string) Option {
+	return func(config *LoggerConfig) error {
+		if layout == "" {
+			return errors.New("glog: empty custom time format")
+		}
+		config.CustomTimeFormat = layout
+		return nil
+	}
+}
+
+// WithOutput sets the writer logs are written to.
+func WithOutput(w io.Writer) Option {
+	return func(config *LoggerConfig) error {
+		if w == nil {
+			return errors.New("glog: nil output")
+		}
+		config.Output = w
+		return nil
+	}
+}
+
+// WithSkip skips logging for the given paths, see LoggerConfig.Skip.
+func WithSkip(paths ...string) Option {
+	return func(config *LoggerConfig) error {
+		config.SkipPaths = append(config.SkipPaths, paths...)
+		return nil
+	}
+}
+
+// WithSkipper sets a predicate deciding which requests are not logged.
+func WithSkipper(skipper func(*gin.Context) bool) Option {
+	return func(config *LoggerConfig) error {
+		if skipper == nil {
+			return errors.New("glog: nil skipper")
+		}
+		config.Skipper = skipper
+		return nil
+	}
+}
+
+// WithRedactFields sets the JSON keys masked in the body and response tags.
+func WithRedactFields(fields ...string) Option {
+	return func(config *LoggerConfig) error {
+		for _, f := range fields {
+			if f == "" {
+				return errors.New("glog: empty redact field")
+			}
+		}
+		config.SensitiveFields = fields
+		return nil
+	}
+}
+
+// WithMaxBodySize caps the captured body and response, see
+// LoggerConfig.MaxBodySize.
+func WithMaxBodySize(n int) Option {
+	return func(config *LoggerConfig) error {
+		if n < 0 {
+			return errors.New("glog: negative max body size")
+		}
+		config.MaxBodySize = n
+		return nil
+	}
+}
+
+// WithAsync enables Async mode with a queue of size entries.
+func WithAsync(size int) Option {
+	return func(config *LoggerConfig) error {
+		if size <= 0 {
+			return errors.New("glog: async queue size must be positive")
+		}
+		config.Async = true
+		config.QueueSize = size
+		return nil
+	}
+}
+
+// WithFlushInterval batches Async entries and writes them at least every d,
+// see LoggerConfig.FlushInterval.
+func WithFlushInterval(d time.Duration) Option {
+	return func(config *LoggerConfig) error {
+		if d <= 0 {
+			return errors.New("glog: flush interval must be positive")
+		}
+		config.FlushInterval = d
+		return nil
+	}
+}
+
+// WithCustomTag registers a tag computed by f, see LoggerConfig.CustomTags.
+func WithCustomTag(name string, f func(*gin.Context) string) Option {
+	return func(config *LoggerConfig) error {
+		if name == "" || f == nil {
+			return errors.New("glog: custom tag needs a name and a function")
+		}
+		tags := make(map[string]func(*gin.Context) string, len(config.CustomTags)+1)
+		for k, v := range config.CustomTags {
+			tags[k] = v
+		}
+		tags[name] = f
+		config.CustomTags = tags
+		return nil
+	}
+}
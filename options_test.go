@@ -0,0 +1,56 @@
+package glog
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		opts []Option
+		want string
+	}{
+		{[]Option{WithFormat("${status}\n"), WithPreset(PresetConsole)}, `glog: WithPreset("console") conflicts with WithFormat`},
+		{[]Option{WithPreset(PresetJSON), WithFormat("${status}\n")}, `glog: WithFormat conflicts with WithPreset("json")`},
+		{[]Option{WithPreset(PresetJSON), WithPreset(PresetStructured)}, `glog: WithPreset("structured") conflicts with WithPreset("json")`},
+		{[]Option{WithFormat("a\n"), WithFormat("b\n")}, "glog: WithFormat conflicts with WithFormat"},
+		{[]Option{WithPreset("xml")}, `glog: unknown preset "xml"`},
+	} {
+		if _, _, err := New(tc.opts...); err == nil || err.Error() != tc.want {
+			t.Errorf("error %v, want %q", err, tc.want)
+		}
+	}
+}
+
+func TestNewPreset(t *testing.T) {
+	serveWith := func(opts ...Option) string {
+		out := new(syncBuffer)
+		handler, _, err := New(append(opts, WithOutput(out))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := gin.New()
+		r.Use(handler)
+		r.GET("/", func(ctx *gin.Context) {})
+		serve(r, "GET", "/", nil)
+		return out.String()
+	}
+
+	if line := serveWith(WithPreset(PresetConsole)); !strings.Contains(line, " | 200 | ") || strings.Contains(line, `"`) {
+		t.Errorf("console preset entry = %q", line)
+	}
+	line := serveWith(WithPreset(PresetConsole), WithCustomTimeFormat("15h04"))
+	if i := strings.IndexByte(line, ' '); i != 5 || line[2] != 'h' {
+		t.Errorf("console preset with WithCustomTimeFormat: entry = %q, want the custom time", line)
+	}
+	for _, preset := range []string{PresetJSON, PresetStructured} {
+		var entry map[string]interface{}
+		line := serveWith(WithPreset(preset))
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry["status"] != 200.0 {
+			t.Errorf("%s preset entry = %q (%v)", preset, line, err)
+		}
+	}
+}
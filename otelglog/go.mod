@@ -0,0 +1,11 @@
+module github.com/zt-tech/glog/otelglog
+
+go 1.15
+
+require (
+	github.com/gin-gonic/gin v1.5.0
+	github.com/zt-tech/glog v0.0.0-20261015123851-359117ec46f5
+	go.opentelemetry.io/otel/trace v1.0.0
+)
+
+replace github.com/zt-tech/glog => ../
@@ -0,0 +1,22 @@
+// Package otelglog connects glog to OpenTelemetry. It is a module of its
+// own, so only the applications using it depend on OpenTelemetry.
+package otelglog
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceContext returns the trace and span IDs of the span in the context
+// of r, e.g. started by otelgin, and empty strings when there is none. Use
+// it as glog.LoggerConfig.TraceContext for the trace_id and span_id tags:
+//
+//	config.TraceContext = otelglog.TraceContext
+func TraceContext(r *http.Request) (traceID, spanID string) {
+	sc := trace.SpanContextFromContext(r.Context())
+	if !sc.IsValid() {
+		return "", ""
+	}
+	return sc.TraceID().String(), sc.SpanID().String()
+}
@@ -0,0 +1,68 @@
+package otelglog
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zt-tech/glog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+var (
+	traceID = trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	spanID  = trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}
+)
+
+// router logs trace_id and span_id to out. withSpan puts a span context in
+// the request context like a tracing middleware.
+func router(out *bytes.Buffer, withSpan bool) *gin.Engine {
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) {
+		if withSpan {
+			sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled})
+			ctx.Request = ctx.Request.WithContext(trace.ContextWithSpanContext(ctx.Request.Context(), sc))
+		}
+	})
+	r.Use(glog.LoggerWithConfig(glog.LoggerConfig{
+		Format:       "${trace_id} ${span_id}\n",
+		Output:       out,
+		TraceContext: TraceContext,
+	}))
+	r.GET("/", func(ctx *gin.Context) {})
+	return r
+}
+
+func TestTraceContext(t *testing.T) {
+	out := new(bytes.Buffer)
+	router(out, true).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if want := "4bf92f3577b34da6a3ce929d0e0e4736 00f067aa0ba902b7\n"; out.String() != want {
+		t.Errorf("entry = %q, want %q", out, want)
+	}
+}
+
+func TestTraceContextWithoutSpan(t *testing.T) {
+	out := new(bytes.Buffer)
+	r := router(out, false)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if out.String() != " \n" {
+		t.Errorf("entry = %q, want empty IDs", out)
+	}
+	// Without a span the traceparent header is still used.
+	out.Reset()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if want := "0af7651916cd43dd8448eb211c80319c b7ad6b7169203331\n"; out.String() != want {
+		t.Errorf("entry = %q, want %q", out, want)
+	}
+	if id, _ := TraceContext(&http.Request{}); id != "" {
+		t.Errorf("TraceContext without a context = %q", id)
+	}
+}
@@ -0,0 +1,83 @@
+package glog
+
+import (
+	"io"
+
+	"github.com/zt-tech/glog/color"
+)
+
+// extraOutput is one of Outputs after the first, which becomes Output.
+type extraOutput struct {
+	w       io.Writer
+	colorer *color.Color
+	// async is set in Async mode, each output then has its own queue.
+	async *asyncWriter
+}
+
+// newExtraOutputs prepares Outputs[1:]. It is called once Output and the
+// Async settings are final.
+func (config *LoggerConfig) newExtraOutputs() []*extraOutput {
+	if len(config.Outputs) < 2 {
+		return nil
+	}
+	outs := make([]*extraOutput, 0, len(config.Outputs)-1)
+	for _, w := range config.Outputs[1:] {
+		o := &extraOutput{w: w, colorer: config.newColorer(w)}
+		if config.Async {
+			o.async = newAsyncWriter(o.w, config.outputFailures, config.QueueSize, config.BlockOnFull, config.FlushInterval, config.BatchSize)
+		}
+		outs = append(outs, o)
+	}
+	return outs
+}
+
+// asyncSideOutputs replaces ErrorOutput, AbortedOutput and ShadowOutput
+// with async writers, like Outputs[1:]. A writer that is Output or another
+// of them shares its queue, so their entries stay ordered. Failures are
+// counted where the synchronous writes count them.
+func (config *LoggerConfig) asyncSideOutputs(shadowFailures *uint64) {
+	errFailures := config.outputFailures
+	if config.ErrorOutputOnly {
+		errFailures = config.dropped
+	}
+	type side struct {
+		w        *io.Writer
+		failures *uint64
+	}
+	sides := []side{
+		{&config.ErrorOutput, errFailures},
+		{&config.AbortedOutput, config.dropped},
+	}
+	if config.shadowTemplate != nil {
+		sides = append(sides, side{&config.ShadowOutput, shadowFailures})
+	}
+	writers := []io.Writer{config.Output}
+	queues := []*asyncWriter{config.async}
+	for _, s := range sides {
+		if *s.w == nil {
+			continue
+		}
+		shared := false
+		for i, w := range writers {
+			if containsWriter([]io.Writer{w}, *s.w) {
+				*s.w, shared = queues[i], true
+				break
+			}
+		}
+		if shared {
+			continue
+		}
+		a := newAsyncWriter(*s.w, s.failures, config.QueueSize, config.BlockOnFull, config.FlushInterval, config.BatchSize)
+		writers, queues = append(writers, *s.w), append(queues, a)
+		config.sideAsync = append(config.sideAsync, a)
+		*s.w = a
+	}
+}
+
+func (config *LoggerConfig) writeExtra(o *extraOutput, b []byte) {
+	if o.async != nil {
+		config.writeTo(o.async, b, config.outputFailures)
+		return
+	}
+	config.writeTo(o.w, b, config.outputFailures)
+}
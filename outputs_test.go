@@ -0,0 +1,32 @@
+package glog
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestOutputs(t *testing.T) {
+	a, b, c := new(syncBuffer), new(syncBuffer), new(syncBuffer)
+	r, _, m := newTestRouter(t, LoggerConfig{Format: "${path}\n", Outputs: []io.Writer{a, failingWriter{}, b, c}})
+	r.GET("/:n", func(ctx *gin.Context) {})
+	serve(r, "GET", "/1", nil)
+	serve(r, "GET", "/2", nil)
+	want := []string{"/1", "/2"}
+	for i, w := range []*syncBuffer{a, b, c} {
+		if got := w.Lines(); !reflect.DeepEqual(got, want) {
+			t.Errorf("writer %d: lines = %q, want %q", i, got, want)
+		}
+	}
+	if s := m.Stats(); s.OutputFailures != 2 || s.Dropped != 0 {
+		t.Errorf("stats %+v, want the 2 failed writes counted as output failures", s)
+	}
+}
@@ -0,0 +1,41 @@
+package glog
+
+import (
+	"net/url"
+	"strings"
+)
+
+// redactQuery masks the values of sensitive parameters in the raw query.
+// Parameter names are compared after decoding, so encoded names are
+// matched too. When decode is true keys and values are URL-decoded.
+func (config *LoggerConfig) redactQuery(raw string, decode bool) string {
+	if raw == "" {
+		return ""
+	}
+	parts := strings.Split(raw, "&")
+	for i, part := range parts {
+		key, value, hasValue := part, "", false
+		if j := strings.IndexByte(part, '='); j >= 0 {
+			key, value, hasValue = part[:j], part[j+1:], true
+		}
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if decode {
+			key = name
+			if v, err := url.QueryUnescape(value); err == nil {
+				value = v
+			}
+		}
+		if _, ok := config.sensitive[strings.ToLower(name)]; ok && hasValue {
+			value = "***"
+		}
+		if hasValue {
+			parts[i] = key + "=" + value
+		} else {
+			parts[i] = key
+		}
+	}
+	return strings.Join(parts, "&")
+}
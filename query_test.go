@@ -0,0 +1,32 @@
+package glog
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestQuery(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${query}|${query_decoded}\n", SensitiveFields: []string{"password", "clé"}})
+	r.GET("/", func(ctx *gin.Context) {})
+	for _, q := range []string{
+		"q=hello%20world&n=1+2",
+		"pass%77ord=se%20cret&x=1",
+		"cl%C3%A9=%E2%82%AC&nom=%E6%97%A5%E6%9C%AC+go",
+		"flag&password",
+		"",
+	} {
+		serve(r, "GET", "/?"+q, nil)
+	}
+	want := []string{
+		"q=hello%20world&n=1+2|q=hello world&n=1 2",
+		"pass%77ord=***&x=1|password=***&x=1",
+		"cl%C3%A9=***&nom=%E6%97%A5%E6%9C%AC+go|clé=***&nom=日本 go",
+		"flag&password|flag&password",
+		"|",
+	}
+	if got := out.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package glog
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recovered describes a panic caught by Recover.
+type recovered struct {
+	value interface{}
+	stack []byte
+	// brokenPipe is set when the client went away, see isBrokenPipe.
+	brokenPipe bool
+}
+
+// next runs the chain. With Recover a panic is turned into a 500 response
+// and an error recorded with ctx.Error, so the request is logged at level
+// "error" as usual. A broken pipe gets no response and no stack. The
+// returned value is nil when nothing panicked.
+func (config *LoggerConfig) next(ctx *gin.Context) (p *recovered) {
+	if !config.Recover {
+		ctx.Next()
+		return nil
+	}
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		p = &recovered{value: r}
+		_ = ctx.Error(fmt.Errorf("panic: %v", r))
+		if isBrokenPipe(r) {
+			// Like gin's Recovery: no stack, and the connection is gone
+			// so no response is written either.
+			p.brokenPipe = true
+			ctx.Abort()
+			return
+		}
+		p.stack = debug.Stack()
+		if config.repanic(p) {
+			// Record the status for the entry, the outer recovery
+			// writes the response.
+			if !ctx.Writer.Written() {
+				ctx.Writer.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+	}()
+	ctx.Next()
+	return nil
+}
+
+// repanic reports whether p is raised again once the entry is logged.
+// http.ErrAbortHandler is always raised again for net/http, a broken pipe
+// never.
+func (config *LoggerConfig) repanic(p *recovered) bool {
+	if p.brokenPipe {
+		return false
+	}
+	return config.RepanicAfterLog || p.value == http.ErrAbortHandler
+}
+
+// isBrokenPipe reports whether the panic value v is a write error caused by
+// the client closing the connection.
+func isBrokenPipe(v interface{}) bool {
+	ne, ok := v.(*net.OpError)
+	if !ok {
+		return false
+	}
+	se, ok := ne.Err.(*os.SyscallError)
+	if !ok {
+		return false
+	}
+	msg := strings.ToLower(se.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
+
+// stackText returns the stack of p on one line, with newlines and tabs
+// escaped.
+func (p *recovered) stackText() string {
+	if p == nil {
+		return ""
+	}
+	return strings.NewReplacer("\n", `\n`, "\t", `\t`).Replace(strings.TrimSpace(string(p.stack)))
+}
@@ -0,0 +1,96 @@
+package glog
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveRecovering serves a GET of target like serve and returns the value
+// of a panic raised through the middleware.
+func serveRecovering(r http.Handler, target string) (w *httptest.ResponseRecorder, p interface{}) {
+	defer func() { p = recover() }()
+	return serve(r, "GET", target, nil), nil
+}
+
+// panicRouter returns a router with Recover whose /panic route panics
+// with the value registered in values under the query parameter v.
+func panicRouter(t *testing.T, config LoggerConfig, values map[string]interface{}) (*gin.Engine, *syncBuffer) {
+	config.Format = "${status} ${level} ${error} ${stack}\n"
+	config.Recover = true
+	r, out, _ := newTestRouter(t, config)
+	r.GET("/panic", func(ctx *gin.Context) {
+		panic(values[ctx.Query("v")])
+	})
+	return r, out
+}
+
+func TestRecover(t *testing.T) {
+	r, out := panicRouter(t, LoggerConfig{}, map[string]interface{}{"boom": "boom"})
+	w, p := serveRecovering(r, "/panic?v=boom")
+	if p != nil {
+		t.Fatalf("panic %v raised again", p)
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status %d, want 500", w.Code)
+	}
+	fields := strings.SplitN(strings.TrimSuffix(out.String(), "\n"), " ", 5)
+	if len(fields) != 5 {
+		t.Fatalf("entry = %q", out)
+	}
+	if fields[0] != "500" || fields[1] != "error" || fields[2]+" "+fields[3] != "panic: boom" {
+		t.Errorf("entry = %q, want status 500, level error and the panic", out)
+	}
+	if stack := fields[4]; !strings.Contains(stack, "goroutine") || !strings.Contains(stack, "recover_test.go") || strings.Contains(stack, "\n") {
+		t.Errorf("stack = %q, want the stack of the handler on one line", stack)
+	}
+}
+
+func TestRepanic(t *testing.T) {
+	values := map[string]interface{}{"boom": "boom", "abort": http.ErrAbortHandler}
+	for _, tc := range []struct {
+		repanic bool
+		value   string
+		want    interface{}
+	}{
+		{true, "boom", "boom"},
+		{false, "abort", http.ErrAbortHandler},
+		{true, "abort", http.ErrAbortHandler},
+	} {
+		r, out := panicRouter(t, LoggerConfig{RepanicAfterLog: tc.repanic}, values)
+		_, p := serveRecovering(r, "/panic?v="+tc.value)
+		if p != tc.want {
+			t.Errorf("RepanicAfterLog %v, %s: raised %v, want %v", tc.repanic, tc.value, p, tc.want)
+		}
+		if lines := out.Lines(); len(lines) != 1 || !strings.HasPrefix(lines[0], "500 error panic: ") {
+			t.Errorf("RepanicAfterLog %v, %s: entries %q, want the entry written before the panic", tc.repanic, tc.value, lines)
+		}
+	}
+}
+
+func TestBrokenPipe(t *testing.T) {
+	values := map[string]interface{}{
+		"epipe":      &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)},
+		"econnreset": &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.ECONNRESET)},
+	}
+	for name := range values {
+		r, out := panicRouter(t, LoggerConfig{RepanicAfterLog: true}, values)
+		_, p := serveRecovering(r, "/panic?v="+name)
+		if p != nil {
+			t.Errorf("%s: raised %v again", name, p)
+		}
+		line := out.String()
+		if !strings.Contains(line, " error panic: write tcp") || !strings.HasSuffix(line, " \n") {
+			t.Errorf("%s: entry = %q, want the error and no stack", name, line)
+		}
+	}
+	if isBrokenPipe(&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}) {
+		t.Error("connection refused reported as a broken pipe")
+	}
+}
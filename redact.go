@@ -0,0 +1,146 @@
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var newlineRegexp = regexp.MustCompile("\n *")
+
+// newRedactor compiles a regexp matching a JSON key/value pair for any of
+// the given field names, ignoring case. It is only used for text that does
+// not parse as JSON, e.g. a truncated body. It returns nil when there is
+// nothing to mask.
+func newRedactor(fields []string) *regexp.Regexp {
+	if len(fields) == 0 {
+		return nil
+	}
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = regexp.QuoteMeta(f)
+	}
+	return regexp.MustCompile(`(?i)"(` + strings.Join(names, "|") + `)"\s*:\s*("(?:[^"\\]|\\.)*"|[^\s,{}\[\]"]+)`)
+}
+
+// redact puts s on a single line and masks the values of the configured
+// sensitive fields. Valid JSON is re-encoded compactly with the values of
+// sensitive keys, at any depth, replaced by "***" and everything else kept
+// as is. Other text falls back to removing line breaks, see CompactBody,
+// and to masking the key/value pairs found by a regexp.
+func (config *LoggerConfig) redact(s string) string {
+	if out, ok := config.redactJSON(s); ok {
+		return out
+	}
+	if config.CompactBody {
+		s = compact(s)
+	} else {
+		s = newlineRegexp.ReplaceAllString(s, "")
+	}
+	if config.redactor != nil {
+		s = config.redactor.ReplaceAllString(s, `"$1":"***"`)
+	}
+	return s
+}
+
+// redactJSON re-encodes s with the sensitive values masked. It reports
+// false when s is not a single JSON object or array.
+func (config *LoggerConfig) redactJSON(s string) (string, bool) {
+	t := strings.TrimSpace(s)
+	if t == "" || t[0] != '{' && t[0] != '[' {
+		return "", false
+	}
+	dec := json.NewDecoder(strings.NewReader(t))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	tok, err := dec.Token()
+	if err != nil {
+		return "", false
+	}
+	if err := config.redactValue(dec, &buf, tok); err != nil {
+		return "", false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// redactValue writes the value starting with tok to buf.
+func (config *LoggerConfig) redactValue(dec *json.Decoder, buf *bytes.Buffer, tok json.Token) error {
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return writeJSONScalar(buf, tok)
+	}
+	buf.WriteByte(byte(delim))
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		masked := false
+		if delim == '{' {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if err := writeJSONScalar(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			_, masked = config.sensitive[strings.ToLower(key.(string))]
+		}
+		v, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if masked {
+			buf.WriteString(`"***"`)
+			err = skipJSONValue(dec, v)
+		} else {
+			err = config.redactValue(dec, buf, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	end, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	buf.WriteByte(byte(end.(json.Delim)))
+	return nil
+}
+
+// skipJSONValue consumes the rest of the value starting with tok.
+func skipJSONValue(dec *json.Decoder, tok json.Token) error {
+	if d, ok := tok.(json.Delim); !ok || d != '{' && d != '[' {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := t.(json.Delim); ok {
+			if d == '{' || d == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+	}
+	return nil
+}
+
+func writeJSONScalar(buf *bytes.Buffer, tok json.Token) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(tok); err != nil {
+		return err
+	}
+	// Drop the newline added by Encode.
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
@@ -0,0 +1,97 @@
+package glog
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// truncatedBody is not valid JSON, so redact falls back to the regexp.
+const truncatedBody = `{"user":"alice","password":"hunter2","items":[1,2,3],"token":"abc","more":[4,5`
+
+func TestRedactTruncated(t *testing.T) {
+	m, err := newMiddleware(LoggerConfig{Output: discard{}, SensitiveFields: []string{"password", "token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"user":"alice","password":"***","items":[1,2,3],"token":"***","more":[4,5`
+	if got := m.config.redact(truncatedBody); got != want {
+		t.Errorf("redact = %s, want %s", got, want)
+	}
+}
+
+func benchmarkRedact(b *testing.B, compileEachCall bool) {
+	m, err := newMiddleware(LoggerConfig{Output: discard{}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	config := &m.config
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if compileEachCall {
+			config.redactor = newRedactor(config.SensitiveFields)
+		}
+		config.redact(truncatedBody)
+	}
+}
+
+// BenchmarkRedact masks a body with the expression compiled once by
+// newMiddleware.
+func BenchmarkRedact(b *testing.B) { benchmarkRedact(b, false) }
+
+// BenchmarkRedactCompileEachCall compiles the expression for every body,
+// as the middleware did before, for comparison:
+//
+//	go test -run - -bench Redact -benchtime 100000x
+func BenchmarkRedactCompileEachCall(b *testing.B) { benchmarkRedact(b, true) }
+
+func TestRedactNested(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${body}\n", SensitiveFields: []string{"password", "Token"}})
+	r.POST("/login", func(ctx *gin.Context) {})
+	serve(r, "POST", "/login", strings.NewReader(`{"user":"alice","password":"hunter2","profile":{"TOKEN":"abc","name":"a, b"},"keys":[{"password":null},{"token":{"v":1}}],"n":1}`))
+	want := `{"user":"alice","password":"***","profile":{"TOKEN":"***","name":"a, b"},"keys":[{"password":"***"},{"token":"***"}],"n":1}`
+	if got := strings.Join(out.Lines(), "\n"); got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(want), &v); err != nil {
+		t.Errorf("masked body is not JSON: %v", err)
+	}
+}
+
+func TestRedactKeepsContent(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${body}\n"})
+	r.POST("/", func(ctx *gin.Context) {})
+	for _, tc := range []struct{ body, want string }{
+		{`{"note":"my password hint is x","a":1}`, `{"note":"my password hint is x","a":1}`},
+		{"{\n  \"text\": \"line1\\nline2\",\n  \"password\": \"x\",\n  \"password_hint\": \"y\"\n}", `{"text":"line1\nline2","password":"***","password_hint":"y"}`},
+		{`{"a":"\"password\":\"x\""}`, `{"a":"\"password\":\"x\""}`},
+	} {
+		serve(r, "POST", "/", strings.NewReader(tc.body))
+		lines := out.Lines()
+		if got := lines[len(lines)-1]; got != tc.want {
+			t.Errorf("body %s logged as %s, want %s", tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestRedactStructural(t *testing.T) {
+	m, err := newMiddleware(LoggerConfig{Output: discard{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for body, want := range map[string]string{
+		`{"user":{"password":"x"},"password":1234}`:                         `{"user":{"password":"***"},"password":"***"}`,
+		`[{"password":"a"},[{"id":1,"password":["b",{"c":2}]}],"password"]`: `[{"password":"***"},[{"id":1,"password":"***"}],"password"]`,
+		`{"a":[[[{"PassWord":{"x":[1]},"b":[true,null,1.5e3]}]]]}`:          `{"a":[[[{"PassWord":"***","b":[true,null,1.5e3]}]]]}`,
+		`{"password":"x"} trailing`:                                         `{"password":"***"} trailing`,
+		`plain "password": secret, text`:                                    `plain "password":"***", text`,
+	} {
+		if got := m.config.redact(body); got != want {
+			t.Errorf("redact(%s) = %s, want %s", body, got, want)
+		}
+	}
+}
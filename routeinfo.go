@@ -0,0 +1,63 @@
+package glog
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRouteInfo bounds the number of routes whose RouteInfoProvider result
+// is memoized. Routes beyond it call the provider on every request.
+const maxRouteInfo = 1000
+
+// routeInfo memoizes RouteInfoProvider per method and route template.
+type routeInfo struct {
+	provider func(method, route string) map[string]string
+	mu       sync.RWMutex
+	cache    map[string]map[string]string
+	// failures counts the provider calls that panicked.
+	failures uint64
+}
+
+func newRouteInfo(provider func(method, route string) map[string]string) *routeInfo {
+	return &routeInfo{provider: provider, cache: make(map[string]map[string]string)}
+}
+
+// lookup returns the pairs of the route matched by ctx, nil for requests
+// matching no route.
+func (r *routeInfo) lookup(ctx *gin.Context) map[string]string {
+	route := ctx.FullPath()
+	if route == "" {
+		return nil
+	}
+	key := ctx.Request.Method + " " + route
+	r.mu.RLock()
+	info, ok := r.cache[key]
+	r.mu.RUnlock()
+	if ok {
+		return info
+	}
+	info, ok = r.call(ctx.Request.Method, route)
+	if !ok {
+		// Not memoized, a later request may succeed.
+		return nil
+	}
+	r.mu.Lock()
+	if len(r.cache) < maxRouteInfo {
+		r.cache[key] = info
+	}
+	r.mu.Unlock()
+	return info
+}
+
+// call runs the provider, recovering and counting panics.
+func (r *routeInfo) call(method, route string) (info map[string]string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			atomic.AddUint64(&r.failures, 1)
+			info, ok = nil, false
+		}
+	}()
+	return r.provider(method, route), true
+}
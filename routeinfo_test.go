@@ -0,0 +1,75 @@
+package glog
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRouteInfo(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	provider := func(method, route string) map[string]string {
+		mu.Lock()
+		calls[method+" "+route]++
+		mu.Unlock()
+		return map[string]string{"team": "team" + route}
+	}
+	r, out, m := newTestRouter(t, LoggerConfig{Format: "${route_info:team}\n", RouteInfoProvider: provider})
+	r.GET("/users/:id", func(ctx *gin.Context) {})
+	r.POST("/users/:id", func(ctx *gin.Context) {})
+	r.GET("/orders", func(ctx *gin.Context) {})
+	serve(r, "GET", "/users/1", nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(r, "GET", "/users/1", nil)
+		}()
+	}
+	wg.Wait()
+	serve(r, "POST", "/users/2", nil)
+	serve(r, "GET", "/orders", nil)
+	serve(r, "GET", "/orders", nil)
+	serve(r, "GET", "/missing", nil)
+
+	if want := map[string]int{"GET /users/:id": 1, "POST /users/:id": 1, "GET /orders": 1}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("provider calls = %v, want %v", calls, want)
+	}
+	lines := out.Lines()
+	if want := []string{"team/users/:id", "team/orders", "team/orders", ""}; !reflect.DeepEqual(lines[10:], want) {
+		t.Errorf("entries = %q, want %q", lines[10:], want)
+	}
+	if n := m.Stats().RouteInfoFailures; n != 0 {
+		t.Errorf("RouteInfoFailures = %d, want 0", n)
+	}
+}
+
+func TestRouteInfoPanic(t *testing.T) {
+	calls := 0
+	provider := func(method, route string) map[string]string {
+		calls++
+		if calls <= 2 {
+			panic("registry down")
+		}
+		return map[string]string{"team": "core"}
+	}
+	r, out, m := newTestRouter(t, LoggerConfig{Format: "${status} ${route_info:team}\n", RouteInfoProvider: provider})
+	r.GET("/", func(ctx *gin.Context) {})
+	for i := 0; i < 4; i++ {
+		serve(r, "GET", "/", nil)
+	}
+	// Failures are not memoized, the third call succeeds and is kept.
+	if want := []string{"200 ", "200 ", "200 core", "200 core"}; !reflect.DeepEqual(out.Lines(), want) {
+		t.Errorf("entries = %q, want %q", out.Lines(), want)
+	}
+	if calls != 3 {
+		t.Errorf("provider called %d times, want 3", calls)
+	}
+	if n := m.Stats().RouteInfoFailures; n != 2 {
+		t.Errorf("RouteInfoFailures = %d, want 2", n)
+	}
+}
@@ -0,0 +1,137 @@
+package glog
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxStatsRoutes bounds the routes tracked by CollectStats, further
+	// routes are counted under otherRoutes.
+	maxStatsRoutes = 1000
+	otherRoutes    = "*other*"
+	// latencyBuckets is the number of latency buckets, the upper bound of
+	// bucket i is 100µs << i and the last one is unbounded.
+	latencyBuckets = 22
+	firstBucket    = 100 * time.Microsecond
+)
+
+type (
+	// RouteStats aggregates the requests of one route template since the
+	// middleware was built. Unmatched requests have an empty Route.
+	RouteStats struct {
+		Route    string `json:"route"`
+		Requests uint64 `json:"requests"`
+		// Errors counts the requests at level "error".
+		Errors uint64 `json:"errors"`
+		// P50 and P99 are upper bounds of the latency quantiles, from
+		// buckets doubling from 100µs.
+		P50 time.Duration `json:"p50"`
+		P99 time.Duration `json:"p99"`
+	}
+
+	// routeCounters holds the counters of one route, updated atomically.
+	routeCounters struct {
+		requests uint64
+		errors   uint64
+		buckets  [latencyBuckets]uint64
+	}
+
+	// routeStats maps the route templates to their counters.
+	routeStats struct {
+		routes sync.Map
+		n      int64
+	}
+)
+
+// add records one request of route.
+func (s *routeStats) add(route string, latency time.Duration, level Level) {
+	v, ok := s.routes.Load(route)
+	if !ok {
+		if atomic.LoadInt64(&s.n) >= maxStatsRoutes {
+			route = otherRoutes
+		}
+		var loaded bool
+		if v, loaded = s.routes.LoadOrStore(route, new(routeCounters)); !loaded {
+			atomic.AddInt64(&s.n, 1)
+		}
+	}
+	c := v.(*routeCounters)
+	atomic.AddUint64(&c.requests, 1)
+	if level == LevelError {
+		atomic.AddUint64(&c.errors, 1)
+	}
+	i := 0
+	for bound := firstBucket; i < latencyBuckets-1 && latency > bound; bound *= 2 {
+		i++
+	}
+	atomic.AddUint64(&c.buckets[i], 1)
+}
+
+// snapshot returns the stats of every route sorted by route.
+func (s *routeStats) snapshot() []RouteStats {
+	var out []RouteStats
+	s.routes.Range(func(k, v interface{}) bool {
+		c := v.(*routeCounters)
+		var buckets [latencyBuckets]uint64
+		var total uint64
+		for i := range buckets {
+			buckets[i] = atomic.LoadUint64(&c.buckets[i])
+			total += buckets[i]
+		}
+		out = append(out, RouteStats{
+			Route:    k.(string),
+			Requests: atomic.LoadUint64(&c.requests),
+			Errors:   atomic.LoadUint64(&c.errors),
+			P50:      quantile(buckets[:], total, 0.5),
+			P99:      quantile(buckets[:], total, 0.99),
+		})
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Route < out[j].Route })
+	return out
+}
+
+// quantile returns the upper bound of the bucket holding the q quantile,
+// or the lower bound of the last, unbounded, bucket.
+func quantile(buckets []uint64, total uint64, q float64) time.Duration {
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(total) + 0.5)
+	if rank == 0 {
+		rank = 1
+	}
+	last := len(buckets) - 1
+	var seen uint64
+	for i, n := range buckets[:last] {
+		seen += n
+		if seen >= rank {
+			return firstBucket << uint(i)
+		}
+	}
+	return firstBucket << uint(last-1)
+}
+
+// Snapshot returns the per-route stats collected with CollectStats, sorted
+// by route. It returns nil when CollectStats is not set.
+func (m *Middleware) Snapshot() []RouteStats {
+	if m.config.routeStats == nil {
+		return nil
+	}
+	return m.config.routeStats.snapshot()
+}
+
+// SnapshotHandler returns a handler rendering Snapshot as JSON, e.g.
+//
+//	r.GET("/internal/traffic", logger.SnapshotHandler())
+func (m *Middleware) SnapshotHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, m.Snapshot())
+	}
+}
@@ -0,0 +1,99 @@
+package glog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSnapshot(t *testing.T) {
+	clock := newFakeClock()
+	config := LoggerConfig{CollectStats: true, Output: discard{}}
+	config.now = clock.Now
+	r, _, m := newTestRouter(t, config)
+	r.GET("/users/:id", func(ctx *gin.Context) {
+		ms, _ := strconv.Atoi(ctx.Query("ms"))
+		clock.Advance(time.Duration(ms) * time.Millisecond)
+		if ctx.Query("fail") != "" {
+			ctx.Status(http.StatusInternalServerError)
+		}
+	})
+	r.GET("/health", func(ctx *gin.Context) {})
+	for i := 0; i < 98; i++ {
+		target := fmt.Sprintf("/users/%d?ms=1", i)
+		if i%40 == 0 {
+			target += "&fail=1"
+		}
+		serve(r, "GET", target, nil)
+	}
+	serve(r, "GET", "/users/98?ms=50", nil)
+	serve(r, "GET", "/users/99?ms=50", nil)
+	serve(r, "GET", "/health", nil)
+	serve(r, "GET", "/missing", nil)
+
+	want := []RouteStats{
+		{Route: "", Requests: 1, P50: firstBucket, P99: firstBucket},
+		{Route: "/health", Requests: 1, P50: firstBucket, P99: firstBucket},
+		// 1ms falls in the bucket up to 1.6ms, 50ms in the one up to
+		// 51.2ms.
+		{Route: "/users/:id", Requests: 100, Errors: 3, P50: 1600 * time.Microsecond, P99: 51200 * time.Microsecond},
+	}
+	if got := m.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %+v, want %+v", got, want)
+	}
+
+	h := gin.New()
+	h.GET("/", m.SnapshotHandler())
+	var got []RouteStats
+	if err := json.Unmarshal(serve(h, "GET", "/", nil).Body.Bytes(), &got); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("SnapshotHandler = %+v (%v), want %+v", got, err, want)
+	}
+
+	_, _, m = newTestRouter(t, LoggerConfig{Output: discard{}})
+	if m.Snapshot() != nil {
+		t.Error("Snapshot() without CollectStats is not nil")
+	}
+}
+
+func TestSnapshotOverflow(t *testing.T) {
+	r, _, m := newTestRouter(t, LoggerConfig{CollectStats: true, Output: discard{}})
+	for i := 0; i < maxStatsRoutes+2; i++ {
+		route := fmt.Sprintf("/r%d", i)
+		r.GET(route, func(ctx *gin.Context) {})
+		serve(r, "GET", route, nil)
+	}
+	stats := m.Snapshot()
+	if len(stats) != maxStatsRoutes+1 {
+		t.Fatalf("%d routes, want %d and the overflow", len(stats), maxStatsRoutes)
+	}
+	for _, s := range stats {
+		if s.Route == otherRoutes {
+			if s.Requests != 2 {
+				t.Errorf("%s: %d requests, want 2", otherRoutes, s.Requests)
+			}
+			return
+		}
+	}
+	t.Errorf("no %s entry", otherRoutes)
+}
+
+func TestQuantile(t *testing.T) {
+	buckets := make([]uint64, latencyBuckets)
+	if q := quantile(buckets, 0, 0.5); q != 0 {
+		t.Errorf("quantile of no requests = %v, want 0", q)
+	}
+	buckets[0], buckets[latencyBuckets-1] = 1, 1
+	if q := quantile(buckets, 2, 0.01); q != firstBucket {
+		t.Errorf("p1 = %v, want %v", q, firstBucket)
+	}
+	// The last bucket is unbounded, its lower bound is reported.
+	if q, want := quantile(buckets, 2, 0.99), firstBucket<<(latencyBuckets-2); q != want {
+		t.Errorf("p99 = %v, want %v", q, want)
+	}
+}
@@ -0,0 +1,92 @@
+package glog
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adaptiveSampler adjusts the sample probability once per interval so the
+// number of logged entries stays near target per second.
+type adaptiveSampler struct {
+	mu       sync.Mutex
+	target   float64
+	interval time.Duration
+	now      func() time.Time
+	start    time.Time
+	// seen counts the sampling candidates in the current interval.
+	seen int
+	rate float64
+}
+
+func newAdaptiveSampler(target float64, now func() time.Time) *adaptiveSampler {
+	return &adaptiveSampler{
+		target:   target,
+		interval: time.Second,
+		now:      now,
+		start:    now(),
+		rate:     1,
+	}
+}
+
+// next records a candidate and returns the probability it is logged with.
+func (s *adaptiveSampler) next() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if elapsed := now.Sub(s.start); elapsed >= s.interval {
+		observed := float64(s.seen) / elapsed.Seconds()
+		s.rate = 1
+		if observed > s.target {
+			s.rate = s.target / observed
+		}
+		s.start, s.seen = now, 0
+	}
+	s.seen++
+	return s.rate
+}
+
+// current returns the effective probability without recording a candidate.
+func (s *adaptiveSampler) current() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rate
+}
+
+// filtered reports whether an entry is dropped by MinLevel or MinStatus.
+// Slow requests are never filtered.
+func (config *LoggerConfig) filtered(status int, latency time.Duration, level Level) bool {
+	if config.SlowThreshold > 0 && latency >= config.SlowThreshold {
+		return false
+	}
+	return level < config.minLevel || status < config.MinStatus
+}
+
+// sample decides whether the request is logged according to NeverSample,
+// Sampler, SampleRateByStatus, SampleRate and TargetRate, and returns the
+// probability used. Error entries are never dropped by the adaptive
+// sampler.
+func (config *LoggerConfig) sample(ctx *gin.Context, level Level, latency time.Duration) (float64, bool) {
+	if _, ok := config.exempt[ctx.FullPath()]; ok {
+		return 1, true
+	}
+	if config.Sampler != nil {
+		return 1, config.Sampler(ctx, ctx.Writer.Status(), latency)
+	}
+	rate, ok := config.SampleRateByStatus[ctx.Writer.Status()/100]
+	if !ok {
+		rate = config.SampleRate
+		slow := config.SlowThreshold > 0 && latency >= config.SlowThreshold
+		if rate <= 0 || level == LevelError || slow {
+			rate = 1
+		}
+	}
+	if rate > 1 {
+		rate = 1
+	}
+	if config.adaptive != nil && level != LevelError {
+		rate *= config.adaptive.next()
+	}
+	return rate, rate >= 1 || config.random() < rate
+}
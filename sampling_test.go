@@ -0,0 +1,175 @@
+package glog
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusRouter answers /status/:code with that status.
+func statusRouter(t *testing.T, config LoggerConfig) (*gin.Engine, *syncBuffer, *Middleware) {
+	t.Helper()
+	if config.Format == "" {
+		config.Format = "${status}\n"
+	}
+	r, out, m := newTestRouter(t, config)
+	r.GET("/status/:code", func(ctx *gin.Context) {
+		code, _ := strconv.Atoi(ctx.Param("code"))
+		ctx.Status(code)
+	})
+	return r, out, m
+}
+
+func TestSampleRateByStatus(t *testing.T) {
+	r, out, _ := statusRouter(t, LoggerConfig{SampleRateByStatus: map[int]float64{2: 0, 5: 1}})
+	for i := 0; i < 100; i++ {
+		serve(r, "GET", "/status/200", nil)
+		serve(r, "GET", "/status/500", nil)
+	}
+	serve(r, "GET", "/status/404", nil)
+	counts := make(map[string]int)
+	for _, line := range out.Lines() {
+		counts[line]++
+	}
+	if counts["200"] != 0 || counts["500"] != 100 || counts["404"] != 1 {
+		t.Errorf("logged %v, want no 200, 100 500 and the 404", counts)
+	}
+}
+
+func TestNeverSample(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format:             "${path} ${sample_rate}\n",
+		SampleRateByStatus: map[int]float64{2: 0},
+		NeverSample:        []string{"/payments/:id"},
+	})
+	r.GET("/payments/:id", func(ctx *gin.Context) {})
+	r.GET("/users/:id", func(ctx *gin.Context) {})
+	for i := 0; i < 20; i++ {
+		serve(r, "GET", "/payments/"+strconv.Itoa(i), nil)
+		serve(r, "GET", "/users/"+strconv.Itoa(i), nil)
+	}
+	lines := out.Lines()
+	if len(lines) != 20 {
+		t.Fatalf("got %d lines, want the 20 payments", len(lines))
+	}
+	for i, line := range lines {
+		if want := "/payments/" + strconv.Itoa(i) + " 1"; line != want {
+			t.Errorf("line %d = %q, want %q", i, line, want)
+		}
+	}
+}
+
+func TestTargetRate(t *testing.T) {
+	clock := newFakeClock()
+	r, out, m := statusRouter(t, LoggerConfig{Format: "${status} ${sample_rate}\n", TargetRate: 10, now: clock.Now})
+	for i := 0; i < 100; i++ {
+		serve(r, "GET", "/status/200", nil)
+	}
+	if n := len(out.Lines()); n != 100 {
+		t.Errorf("first second: %d lines, want 100", n)
+	}
+	if rate := m.Stats().SampleRate; rate != 1 {
+		t.Errorf("SampleRate = %v, want 1 before the first adjustment", rate)
+	}
+
+	// 100 requests per second for a target of 10 divides the rate by 10.
+	clock.Advance(time.Second)
+	before := len(out.Lines())
+	serve(r, "GET", "/status/500", nil)
+	for i := 0; i < 50; i++ {
+		serve(r, "GET", "/status/200", nil)
+	}
+	if rate := m.Stats().SampleRate; rate != 0.1 {
+		t.Errorf("SampleRate = %v, want 0.1 under load", rate)
+	}
+	lines := out.Lines()[before:]
+	if len(lines) == 0 || lines[0] != "500 1" {
+		t.Fatalf("error entry = %q, want it logged at rate 1", lines)
+	}
+	for _, line := range lines[1:] {
+		if line != "200 0.1" {
+			t.Errorf("entry = %q, want sample_rate 0.1", line)
+		}
+	}
+	if len(lines) > 30 {
+		t.Errorf("%d of 50 entries logged at rate 0.1", len(lines)-1)
+	}
+
+	// Quiet traffic restores it.
+	clock.Advance(10 * time.Second)
+	serve(r, "GET", "/status/200", nil)
+	if rate := m.Stats().SampleRate; rate != 1 {
+		t.Errorf("SampleRate = %v, want 1 once traffic is below the target", rate)
+	}
+	if lines := out.Lines(); lines[len(lines)-1] != "200 1" {
+		t.Errorf("last entry = %q, want sample_rate 1", lines[len(lines)-1])
+	}
+}
+
+func TestSampleRate(t *testing.T) {
+	rendered := 0
+	r, out, _ := statusRouter(t, LoggerConfig{
+		Format:     "${status}${count}\n",
+		SampleRate: 0.1,
+		CustomTags: map[string]func(*gin.Context) string{
+			"count": func(*gin.Context) string { rendered++; return "" },
+		},
+		random: rand.New(rand.NewSource(1)).Float64,
+	})
+	for i := 0; i < 1000; i++ {
+		serve(r, "GET", "/status/200", nil)
+	}
+	for i := 0; i < 10; i++ {
+		serve(r, "GET", "/status/500", nil)
+	}
+	counts := make(map[string]int)
+	for _, line := range out.Lines() {
+		counts[line]++
+	}
+	if n := counts["200"]; n < 80 || n > 120 {
+		t.Errorf("%d of 1000 2xx entries logged at rate 0.1", n)
+	}
+	if counts["500"] != 10 {
+		t.Errorf("%d of 10 error entries logged, want all", counts["500"])
+	}
+	// Dropped requests are never rendered.
+	if rendered != len(out.Lines()) {
+		t.Errorf("%d entries rendered for %d lines", rendered, len(out.Lines()))
+	}
+}
+
+func TestSamplePrecedence(t *testing.T) {
+	never := func() float64 { return 1 }
+	// MinStatus drops entries whatever their rate.
+	r, out, _ := statusRouter(t, LoggerConfig{MinStatus: 500, SampleRate: 0.5, random: never})
+	serve(r, "GET", "/status/404", nil)
+	serve(r, "GET", "/status/503", nil)
+	if got := out.Lines(); !reflect.DeepEqual(got, []string{"503"}) {
+		t.Errorf("MinStatus with sampling logged %q, want the 503", got)
+	}
+
+	// Slow requests pass both MinStatus and sampling.
+	r, out, _ = statusRouter(t, LoggerConfig{
+		Format:        "${status} ${slow} ${sample_rate}\n",
+		MinStatus:     500,
+		SampleRate:    0.01,
+		SlowThreshold: 500 * time.Millisecond,
+		now:           steppingClock(time.Second),
+		random:        never,
+	})
+	serve(r, "GET", "/status/200", nil)
+	if got := out.Lines(); !reflect.DeepEqual(got, []string{"200 true 1"}) {
+		t.Errorf("slow request logged as %q, want it kept at rate 1", got)
+	}
+
+	// Unset, everything is logged.
+	r, out, _ = statusRouter(t, LoggerConfig{random: never})
+	serve(r, "GET", "/status/200", nil)
+	if len(out.Lines()) != 1 {
+		t.Error("entry dropped without sampling")
+	}
+}
@@ -0,0 +1,43 @@
+package glog
+
+import "github.com/gin-gonic/gin"
+
+// sentWriter counts what the handlers write against what the connection
+// accepts, for the bytes_sent and write_error tags.
+type sentWriter struct {
+	gin.ResponseWriter
+	// written is what the handlers wrote, sent what the underlying writer
+	// accepted.
+	written, sent int
+	// err is the first write error, e.g. a broken pipe once the client
+	// went away.
+	err error
+}
+
+func (w *sentWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.count(len(b), n, err)
+	return n, err
+}
+
+func (w *sentWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.count(len(s), n, err)
+	return n, err
+}
+
+func (w *sentWriter) count(written, sent int, err error) {
+	w.written += written
+	w.sent += sent
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+// errorText returns the first write error, "" when there was none.
+func (w *sentWriter) errorText() string {
+	if w == nil || w.err == nil {
+		return ""
+	}
+	return w.err.Error()
+}
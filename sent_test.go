@@ -0,0 +1,66 @@
+package glog
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientClosesEarly(t *testing.T) {
+	const chunk, chunks = 32 << 10, 2048
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${bytes_out} ${bytes_sent} ${write_error}\n"})
+	r.GET("/stream", func(ctx *gin.Context) {
+		b := make([]byte, chunk)
+		for i := 0; i < chunks; i++ {
+			if _, err := ctx.Writer.Write(b); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		}
+	})
+	r.GET("/small", func(ctx *gin.Context) { ctx.String(http.StatusOK, "hello") })
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.CopyN(ioutil.Discard, resp.Body, 4*chunk); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	deadline := time.Now().Add(10 * time.Second)
+	for len(out.Lines()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	fields := strings.SplitN(strings.Join(out.Lines(), "\n"), " ", 3)
+	if len(fields) != 3 {
+		t.Fatalf("entry = %q", out)
+	}
+	written, _ := strconv.Atoi(fields[0])
+	sent, _ := strconv.Atoi(fields[1])
+	if sent < 4*chunk || sent >= written || written >= chunk*chunks {
+		t.Errorf("bytes_out %d, bytes_sent %d: want the client's share below what was attempted", written, sent)
+	}
+	if fields[2] == "" {
+		t.Error("write_error is empty for a broken connection")
+	}
+
+	resp, err = http.Get(server.URL + "/small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if lines := out.Lines(); lines[len(lines)-1] != "5 5 " {
+		t.Errorf("complete response logged as %q, want 5 5 and no error", lines[len(lines)-1])
+	}
+}
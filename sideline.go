@@ -0,0 +1,123 @@
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// sidePair is a key and its JSON encoded value.
+type sidePair struct {
+	key   string
+	value json.RawMessage
+}
+
+// jsonLines reports whether the entries are JSON objects: with Fields, or
+// a Format starting with "{".
+func (config *LoggerConfig) jsonLines() bool {
+	return config.Fields != nil || strings.HasPrefix(strings.TrimSpace(config.Format), "{")
+}
+
+// sideLine renders v, a struct with json tags, as a line that is not an
+// access entry: slow dependency warnings, budget summaries, startup and
+// marker lines and "glog key" lines. It is encoded like the entries, a
+// JSON object for JSON formats and key=value pairs otherwise, with the
+// keys converted per KeyCase. With TamperEvident the line ends with
+// prev_hash and must be rendered and written with the chain locked.
+func (config *LoggerConfig) sideLine(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var pairs []sidePair
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, sidePair{key.(string), value})
+	}
+	if config.chain != nil {
+		h, _ := json.Marshal(config.chain.prevHash())
+		pairs = append(pairs, sidePair{"prev_hash", h})
+	}
+	var buf bytes.Buffer
+	if config.jsonLines() {
+		buf.WriteByte('{')
+	}
+	for i, p := range pairs {
+		key := p.key
+		if config.keyCase != nil {
+			key = config.keyCase(key)
+		}
+		if !config.jsonLines() {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(key)
+			buf.WriteByte('=')
+			buf.WriteString(textValue(p.value))
+			continue
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(p.value)
+	}
+	if config.jsonLines() {
+		buf.WriteByte('}')
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// textValue returns a JSON value for a key=value pair: strings are written
+// as is unless they need quoting, other values as JSON.
+func textValue(value json.RawMessage) string {
+	var s string
+	if json.Unmarshal(value, &s) != nil {
+		return string(value)
+	}
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return r == '"' || r == '=' || unicode.IsSpace(r) || !unicode.IsPrint(r)
+	}) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// writeSide writes the side line of v to Output and the other Outputs,
+// unless level is below MinLevel.
+func (config *LoggerConfig) writeSide(level Level, v interface{}) {
+	if level < config.minLevel {
+		return
+	}
+	if config.chain != nil {
+		config.chain.mu.Lock()
+		defer config.chain.mu.Unlock()
+	}
+	b, err := config.sideLine(v)
+	if err != nil {
+		return
+	}
+	config.write(b)
+	for _, o := range config.extraOutputs {
+		config.writeExtra(o, b)
+	}
+	if config.chain != nil {
+		config.chain.link(b)
+	}
+}
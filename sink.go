@@ -0,0 +1,128 @@
+package glog
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodySink receives the complete request and response bodies, streamed
+// while the handlers run instead of being held in memory, e.g. to keep an
+// audit copy next to the access log. Artifacts are keyed by the request
+// ID, so the id tag of the entry references them.
+type BodySink interface {
+	// Open returns the writer receiving the body of the request with the
+	// given ID. kind is "request" or "response". The writer is closed
+	// once the handlers returned.
+	Open(requestID, kind string) (io.WriteCloser, error)
+}
+
+// maxSinkDrain bounds the unread request body copied to the sink once the
+// handlers returned, so a client cannot keep the request busy by sending
+// an endless body nobody reads.
+const maxSinkDrain = 1 << 20
+
+// maxSinkKey bounds the length of a client request ID used as sink key.
+const maxSinkKey = 128
+
+// validSinkKey reports whether a request ID sent by the client may key a
+// BodySink artifact: letters, digits, '-' and '_' only, so it is safe as
+// a file or object name.
+func validSinkKey(id string) bool {
+	if id == "" || len(id) > maxSinkKey {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// sinkWriter forwards to a BodySink writer. The first error is kept and
+// further writes are discarded, so a failing sink never fails the request.
+type sinkWriter struct {
+	w   io.WriteCloser
+	err error
+}
+
+func (s *sinkWriter) Write(p []byte) (int, error) {
+	if s.err == nil {
+		_, s.err = s.w.Write(p)
+	}
+	return len(p), nil
+}
+
+// teeBody copies what the handler reads from the request body to a sink.
+type teeBody struct {
+	io.ReadCloser
+	sink *sinkWriter
+}
+
+func (b teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.sink.Write(p[:n])
+	return n, err
+}
+
+// teeResponseWriter copies the response body to a sink.
+type teeResponseWriter struct {
+	gin.ResponseWriter
+	sink *sinkWriter
+}
+
+func (w teeResponseWriter) Write(b []byte) (int, error) {
+	w.sink.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w teeResponseWriter) WriteString(s string) (int, error) {
+	w.sink.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// openSink opens the sink writer for kind, reporting failures once.
+func (config *LoggerConfig) openSink(requestID, kind string) *sinkWriter {
+	w, err := config.BodySink.Open(requestID, kind)
+	if err != nil {
+		config.diag.warnOnce("body_sink", "body sink: "+err.Error())
+		return nil
+	}
+	return &sinkWriter{w: w}
+}
+
+// teeBodies streams the bodies of the request to BodySink and returns the
+// function finishing the request copy and closing the writers.
+func (config *LoggerConfig) teeBodies(ctx *gin.Context, requestID string) func() {
+	var req, res *sinkWriter
+	var body teeBody
+	if r := ctx.Request; r.Body != nil && r.Body != http.NoBody {
+		if req = config.openSink(requestID, "request"); req != nil {
+			body = teeBody{ReadCloser: r.Body, sink: req}
+			r.Body = body
+		}
+	}
+	if res = config.openSink(requestID, "response"); res != nil {
+		ctx.Writer = teeResponseWriter{ResponseWriter: ctx.Writer, sink: res}
+	}
+	return func() {
+		if req != nil {
+			// Copy what the handlers left unread so the sink gets the
+			// whole body, up to maxSinkDrain.
+			if req.err == nil {
+				n, _ := io.CopyN(ioutil.Discard, body, maxSinkDrain+1)
+				if n > maxSinkDrain {
+					config.diag.warnOnce("body_sink_drain", "body sink: unread request body beyond 1MiB not copied")
+				}
+			}
+			req.w.Close()
+		}
+		if res != nil {
+			res.w.Close()
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package glog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memSink keeps the artifacts of a BodySink in memory.
+type memSink struct {
+	mu     sync.Mutex
+	bodies map[string]*sinkBuffer
+	err    error
+}
+
+type sinkBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *sinkBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (s *memSink) Open(requestID, kind string) (io.WriteCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.bodies == nil {
+		s.bodies = make(map[string]*sinkBuffer)
+	}
+	b := new(sinkBuffer)
+	s.bodies[requestID+"/"+kind] = b
+	return b, nil
+}
+
+func (s *memSink) body(requestID, kind string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bodies[requestID+"/"+kind]
+	if !ok || !b.closed {
+		return "", false
+	}
+	return b.String(), true
+}
+
+func TestBodySink(t *testing.T) {
+	sink := new(memSink)
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${id}\n", BodySink: sink, MaxBodySize: 4})
+	var seen string
+	r.POST("/", func(ctx *gin.Context) {
+		b, _ := ioutil.ReadAll(ctx.Request.Body)
+		seen = string(b)
+		ctx.String(http.StatusOK, strings.ToUpper(seen))
+	})
+	body := strings.Repeat("audit me ", 1000)
+	w := serve(r, "POST", "/", strings.NewReader(body))
+	if seen != body || w.Body.String() != strings.ToUpper(body) {
+		t.Fatal("handler or client did not get the complete body")
+	}
+	id := w.Header().Get("X-Request-ID")
+	if got := out.Lines(); len(got) != 1 || got[0] != id || id == "" {
+		t.Fatalf("entries = %q, want the generated id %q", got, id)
+	}
+	if got, ok := sink.body(id, "request"); !ok || got != body {
+		t.Errorf("request artifact has %d bytes, closed %v", len(got), ok)
+	}
+	if got, ok := sink.body(id, "response"); !ok || got != strings.ToUpper(body) {
+		t.Errorf("response artifact has %d bytes, closed %v", len(got), ok)
+	}
+}
+
+func TestBodySinkUnreadBody(t *testing.T) {
+	sink := new(memSink)
+	r, _, _ := newTestRouter(t, LoggerConfig{Format: "${id}\n", BodySink: sink})
+	r.POST("/", func(ctx *gin.Context) {
+		b := make([]byte, 3)
+		io.ReadFull(ctx.Request.Body, b)
+	})
+	serve(r, "POST", "/", strings.NewReader("partly read"), "X-Request-ID", "r1")
+	if got, _ := sink.body("r1", "request"); got != "partly read" {
+		t.Errorf("request artifact = %q, want the unread part drained", got)
+	}
+}
+
+// endlessBody never ends.
+type endlessBody struct{ read int64 }
+
+func (b *endlessBody) Read(p []byte) (int, error) {
+	b.read += int64(len(p))
+	return len(p), nil
+}
+
+func TestBodySinkDrainLimit(t *testing.T) {
+	sink := new(memSink)
+	diag := new(syncBuffer)
+	r, _, _ := newTestRouter(t, LoggerConfig{Format: "${id}\n", BodySink: sink, DiagnosticsOutput: diag})
+	r.POST("/", func(ctx *gin.Context) {})
+	body := new(endlessBody)
+	req, _ := http.NewRequest("POST", "/", body)
+	req.Header.Set("X-Request-ID", "r1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if body.read > 2*maxSinkDrain {
+		t.Errorf("read %d bytes of an unread body", body.read)
+	}
+	if got, _ := sink.body("r1", "request"); len(got) < maxSinkDrain {
+		t.Errorf("request artifact has %d bytes", len(got))
+	}
+	if !strings.Contains(diag.String(), "not copied") {
+		t.Errorf("diagnostics = %q", diag)
+	}
+}
+
+func TestBodySinkKey(t *testing.T) {
+	sink := new(memSink)
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${id}\n", BodySink: sink})
+	r.POST("/", func(ctx *gin.Context) {})
+	for _, id := range []string{"../../etc/passwd", "a b", strings.Repeat("a", maxSinkKey+1), "abc_DEF-123"} {
+		w := serve(r, "POST", "/", strings.NewReader("x"), "X-Request-ID", id)
+		got := w.Header().Get("X-Request-ID")
+		if valid := validSinkKey(id); valid != (got == id) {
+			t.Errorf("client ID %q: request ID %q", id, got)
+		}
+		if _, ok := sink.body(got, "request"); !ok {
+			t.Errorf("no artifact for %q", got)
+		}
+	}
+	if got := out.Lines(); got[3] != "abc_DEF-123" {
+		t.Errorf("entries = %q", got)
+	}
+}
+
+func TestBodySinkFailure(t *testing.T) {
+	sink := &memSink{err: errors.New("disk full")}
+	diag := new(syncBuffer)
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${status}\n", BodySink: sink, DiagnosticsOutput: diag})
+	r.POST("/", func(ctx *gin.Context) {
+		b, _ := ioutil.ReadAll(ctx.Request.Body)
+		ctx.String(http.StatusOK, string(b))
+	})
+	w := serve(r, "POST", "/", strings.NewReader("x"))
+	if w.Body.String() != "x" || len(out.Lines()) != 1 {
+		t.Errorf("response %q, entries %q", w.Body, out.Lines())
+	}
+	if !strings.Contains(diag.String(), "disk full") {
+		t.Errorf("diagnostics = %q", diag)
+	}
+}
@@ -0,0 +1,46 @@
+package glog
+
+import (
+	"path"
+	"strings"
+)
+
+// skipMatcher matches request paths against the Skip patterns. Patterns
+// ending in "*" match by prefix, patterns containing other glob
+// metacharacters use path.Match and the rest match exactly.
+type skipMatcher struct {
+	exact    map[string]struct{}
+	prefixes []string
+	globs    []string
+}
+
+func (s *skipMatcher) add(pattern string) {
+	switch {
+	case strings.HasSuffix(pattern, "*") && !strings.ContainsAny(pattern[:len(pattern)-1], "*?["):
+		s.prefixes = append(s.prefixes, pattern[:len(pattern)-1])
+	case strings.ContainsAny(pattern, "*?["):
+		s.globs = append(s.globs, pattern)
+	default:
+		if s.exact == nil {
+			s.exact = make(map[string]struct{})
+		}
+		s.exact[pattern] = struct{}{}
+	}
+}
+
+func (s *skipMatcher) match(p string) bool {
+	if _, ok := s.exact[p]; ok {
+		return true
+	}
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	for _, glob := range s.globs {
+		if ok, _ := path.Match(glob, p); ok {
+			return true
+		}
+	}
+	return false
+}
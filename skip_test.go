@@ -0,0 +1,34 @@
+package glog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSkip(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format:    "${path} ${body}\n",
+		Skip:      map[string]struct{}{"/static/*": {}, "/health": {}},
+		SkipPaths: []string{"/users/*/avatar"},
+	})
+	captured := make(map[string]bool)
+	r.Use(func(ctx *gin.Context) {
+		_, ok := ctx.Get(ContextRequestBody)
+		captured[ctx.Request.URL.Path] = ok
+	})
+	r.Any("/*path", func(ctx *gin.Context) {})
+	for _, p := range []string{"/static/js/app.js", "/static/", "/health", "/health/db", "/users/42/avatar", "/users/42/avatar/big", "/api/users", "/staticfiles"} {
+		serve(r, "POST", p, strings.NewReader("data"))
+	}
+	want := []string{"/health/db data", "/users/42/avatar/big data", "/api/users data", "/staticfiles data"}
+	if got := out.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+	// Skipped requests are passed through before any buffering.
+	if captured["/static/js/app.js"] || !captured["/api/users"] {
+		t.Errorf("bodies captured: %v", captured)
+	}
+}
@@ -0,0 +1,106 @@
+package glog
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// startupLine describes the active configuration. Only the number of
+// sensitive fields is reported, never the names.
+type startupLine struct {
+	Message            string            `json:"msg"`
+	Format             string            `json:"format,omitempty"`
+	Fields             map[string]string `json:"fields,omitempty"`
+	Output             string            `json:"output"`
+	SampleRateByStatus map[int]float64   `json:"sample_rate_by_status,omitempty"`
+	SampleRate         float64           `json:"sample_rate,omitempty"`
+	Sampler            bool              `json:"sampler,omitempty"`
+	NeverSample        []string          `json:"never_sample,omitempty"`
+	TargetRate         float64           `json:"target_rate,omitempty"`
+	SensitiveFields    int               `json:"sensitive_fields"`
+	MaxBodyLogSize     int               `json:"max_body_log_size"`
+	MaxResponseLogSize int               `json:"max_response_log_size"`
+}
+
+// writeStartupConfig writes one line describing config to the Outputs.
+func (config *LoggerConfig) writeStartupConfig() {
+	line := startupLine{
+		Message:            "glog config",
+		Fields:             config.Fields,
+		Output:             writerType(config.Output),
+		SampleRateByStatus: config.SampleRateByStatus,
+		SampleRate:         config.SampleRate,
+		Sampler:            config.Sampler != nil,
+		NeverSample:        config.NeverSample,
+		TargetRate:         config.TargetRate,
+		SensitiveFields:    len(config.SensitiveFields),
+		MaxBodyLogSize:     config.MaxBodyLogSize,
+		MaxResponseLogSize: config.MaxResponseLogSize,
+	}
+	if config.Fields == nil {
+		line.Format = config.Format
+	}
+	config.writeSide(LevelInfo, line)
+}
+
+// markerLine is the entry written by LogStartupMarker when the middleware
+// is built and closed. Like startupLine it never carries keys or the names
+// of sensitive fields.
+type markerLine struct {
+	Time            string   `json:"time"`
+	Level           Level    `json:"level"`
+	Event           string   `json:"event"`
+	FormatHash      string   `json:"format_hash"`
+	Encoder         string   `json:"encoder"`
+	SampleRate      float64  `json:"sample_rate"`
+	SensitiveFields int      `json:"sensitive_fields"`
+	Outputs         []string `json:"outputs"`
+	Version         string   `json:"version,omitempty"`
+}
+
+// writeMarker writes a markerLine for event to the Outputs.
+func (config *LoggerConfig) writeMarker(event string) {
+	h := fnv.New64a()
+	if config.Fields == nil {
+		h.Write([]byte(config.Format))
+	} else {
+		b, _ := json.Marshal(config.Fields)
+		h.Write(b)
+	}
+	line := markerLine{
+		Time:            time.Now().Format(time.RFC3339Nano),
+		Level:           LevelInfo,
+		Event:           event,
+		FormatHash:      strconv.FormatUint(h.Sum64(), 16),
+		Encoder:         "template",
+		SampleRate:      config.SampleRate,
+		SensitiveFields: len(config.SensitiveFields),
+		Outputs:         []string{writerType(config.Output)},
+	}
+	switch {
+	case config.fastPath:
+		line.Encoder = "fast_path"
+	case config.Fields != nil:
+		line.Encoder = "fields"
+	}
+	if config.SampleRate == 0 {
+		line.SampleRate = 1
+	}
+	for _, o := range config.extraOutputs {
+		line.Outputs = append(line.Outputs, writerType(o.w))
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		line.Version = info.Main.Version
+	}
+	config.writeSide(line.Level, line)
+}
+
+// writerType names the type of w.
+func writerType(w io.Writer) string {
+	return fmt.Sprintf("%T", w)
+}
@@ -0,0 +1,95 @@
+package glog
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLogStartupConfig(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format:             `{"status":${status}}` + "\n",
+		LogStartupConfig:   true,
+		SensitiveFields:    []string{"password", "ssn"},
+		SampleRateByStatus: map[int]float64{2: 0.5},
+	})
+	r.GET("/", func(ctx *gin.Context) {})
+	for i := 0; i < 3; i++ {
+		serve(r, "GET", "/", nil)
+	}
+	var startup []string
+	for _, line := range out.Lines() {
+		if strings.Contains(line, "glog config") {
+			startup = append(startup, line)
+		}
+	}
+	if len(startup) != 1 {
+		t.Fatalf("got %d startup lines, want 1:\n%s", len(startup), out)
+	}
+	if out.Lines()[0] != startup[0] {
+		t.Error("startup line is not the first line")
+	}
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(startup[0]), &line); err != nil {
+		t.Fatalf("startup line is not JSON: %v", err)
+	}
+	if line["format"] != `{"status":${status}}`+"\n" || line["output"] != "*glog.syncBuffer" || line["sensitive_fields"] != 2.0 {
+		t.Errorf("startup line = %s", startup[0])
+	}
+	if strings.Contains(startup[0], "ssn") || strings.Contains(startup[0], "password") {
+		t.Errorf("startup line names the sensitive fields: %s", startup[0])
+	}
+
+	_, out, _ = newTestRouter(t, LoggerConfig{})
+	if out.String() != "" {
+		t.Errorf("startup line written by default: %q", out)
+	}
+}
+
+func TestLogStartupMarker(t *testing.T) {
+	r, out, m := newTestRouter(t, LoggerConfig{
+		Format:           `{"path":"${path}","body":"${body}"}` + "\n",
+		LogStartupMarker: true,
+		EncryptFields:    []string{"body"},
+		EncryptionKey:    &privateKey(t).PublicKey,
+		SensitiveFields:  []string{"password", "card_number"},
+	})
+	r.GET("/", func(ctx *gin.Context) {})
+	serve(r, "GET", "/", nil)
+	m.Close()
+	var markers []markerLine
+	for _, line := range out.Lines() {
+		if !strings.Contains(line, `"event":"logger_`) {
+			continue
+		}
+		if strings.Contains(line, "card_number") || strings.Contains(line, "wrapped_key") || strings.Contains(line, "key_id") {
+			t.Errorf("marker leaks configuration secrets: %s", line)
+		}
+		var marker markerLine
+		if err := json.Unmarshal([]byte(line), &marker); err != nil {
+			t.Fatalf("marker is not JSON: %v\n%s", err, line)
+		}
+		markers = append(markers, marker)
+	}
+	if len(markers) != 2 || markers[0].Event != "logger_start" || markers[1].Event != "logger_stop" {
+		t.Fatalf("markers = %+v, want logger_start then logger_stop", markers)
+	}
+	start := markers[0]
+	if start.Level != LevelInfo || start.Encoder != "template" || start.SampleRate != 1 || start.SensitiveFields != 2 ||
+		len(start.FormatHash) == 0 || !reflect.DeepEqual(start.Outputs, []string{"*glog.syncBuffer"}) {
+		t.Errorf("logger_start = %+v", start)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, start.Time); err != nil {
+		t.Errorf("time %q: %v", start.Time, err)
+	}
+
+	_, out, m = newTestRouter(t, LoggerConfig{Format: `{"path":"${path}"}` + "\n"})
+	m.Close()
+	if out.String() != "" {
+		t.Errorf("marker written by default: %q", out)
+	}
+}
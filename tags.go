@@ -0,0 +1,68 @@
+package glog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxTagArgLen is the maximum length of the argument of a parameterized
+// tag such as header:<NAME>.
+const maxTagArgLen = 256
+
+// argTagPrefixes are the tag families taking an argument.
+var argTagPrefixes = []string{"header:", "header_out:", "query:", "form:", "cookie:", "param:", "context:", "route_info:", "field:", "env:"}
+
+// validTagArg reports whether arg may be used as the argument of a
+// parameterized tag.
+func validTagArg(arg string) bool {
+	if arg == "" || len(arg) > maxTagArgLen {
+		return false
+	}
+	for i := 0; i < len(arg); i++ {
+		if c := arg[i]; c < 0x20 || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// validateTags checks the arguments of the parameterized tags.
+func validateTags(tags map[string]struct{}) error {
+	for tag := range tags {
+		for _, prefix := range argTagPrefixes {
+			if !strings.HasPrefix(tag, prefix) {
+				continue
+			}
+			arg := tag[len(prefix):]
+			switch {
+			case arg == "":
+				return fmt.Errorf("glog: tag %q: missing argument", tag)
+			case len(arg) > maxTagArgLen:
+				return fmt.Errorf("glog: tag %.40q...: argument longer than %d bytes", tag, maxTagArgLen)
+			case !validTagArg(arg):
+				return fmt.Errorf("glog: tag %q: argument contains control characters", tag)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveStatic returns the StaticFields with their "${env:<NAME>}" values
+// read from the environment, and the values of the env:<NAME> tags.
+func resolveStatic(fields map[string]string, tags map[string]struct{}) (static, env map[string]string) {
+	static = make(map[string]string, len(fields))
+	for name, value := range fields {
+		if strings.HasPrefix(value, "${env:") && strings.HasSuffix(value, "}") {
+			value = os.Getenv(value[6 : len(value)-1])
+		}
+		static[name] = value
+	}
+	env = make(map[string]string)
+	for tag := range tags {
+		if strings.HasPrefix(tag, "env:") {
+			env[tag[4:]] = os.Getenv(tag[4:])
+		}
+	}
+	return static, env
+}
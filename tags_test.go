@@ -0,0 +1,68 @@
+package glog
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCustomTags(t *testing.T) {
+	custom := func(ctx *gin.Context) string { return "custom" }
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format: "${tenant} ${header:X-Tenant} ${query:tenant} ${status}\n",
+		CustomTags: map[string]func(*gin.Context) string{
+			"tenant":          func(ctx *gin.Context) string { return ctx.GetString("tenant") },
+			"header:X-Tenant": custom,
+			"query:tenant":    custom,
+			"status":          custom,
+		},
+	})
+	r.Use(func(ctx *gin.Context) { ctx.Set("tenant", "acme") })
+	r.GET("/", func(ctx *gin.Context) {})
+	serve(r, "GET", "/?tenant=q", nil, "X-Tenant", "h")
+	if want := "acme h q 200\n"; out.String() != want {
+		t.Errorf("entry = %q, want %q", out, want)
+	}
+}
+
+func TestCustomTagsAfterHandlers(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format: "${user} ${response_size}\n",
+		CustomTags: map[string]func(*gin.Context) string{
+			"user": func(ctx *gin.Context) string { return ctx.GetString("user") },
+			"response_size": func(ctx *gin.Context) string {
+				return strconv.Itoa(ctx.Writer.Size())
+			},
+		},
+	})
+	r.GET("/", func(ctx *gin.Context) {
+		ctx.Set("user", "alice")
+		ctx.String(http.StatusOK, "hello")
+	})
+	serve(r, "GET", "/", nil)
+	if want := "alice 5\n"; out.String() != want {
+		t.Errorf("entry = %q, want %q", out, want)
+	}
+}
+
+func TestArgTags(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{
+		Format: "${param:id}|${param:missing}|${header_out:X-Cache}|${header_out:Set-Cookie}|${header_out:X-None}\n",
+	})
+	r.GET("/users/:id", func(ctx *gin.Context) {
+		ctx.Header("X-Cache", "HIT")
+		ctx.Header("Set-Cookie", "sid=SECRET")
+	})
+	serve(r, "GET", "/users/42", nil)
+	if want := "42||HIT|***|\n"; out.String() != want {
+		t.Errorf("entry = %q, want %q", out, want)
+	}
+
+	for _, tag := range []string{"${param:}", "${header_out:}"} {
+		if _, err := newMiddleware(LoggerConfig{Format: tag, Output: discard{}}); err == nil {
+			t.Errorf("%s accepted without argument", tag)
+		}
+	}
+}
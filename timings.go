@@ -0,0 +1,43 @@
+package glog
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextMiddlewareTimings holds the durations recorded by
+// RecordMiddleware, a map[string]time.Duration.
+const ContextMiddlewareTimings = "context_middleware_timings"
+
+// RecordMiddleware records that the middleware name took d, for the
+// middleware_timings tag. Durations recorded twice under the same name add
+// up.
+//
+//	start := time.Now()
+//	authenticate(ctx)
+//	glog.RecordMiddleware(ctx, "auth", time.Since(start))
+//	ctx.Next()
+func RecordMiddleware(ctx *gin.Context, name string, d time.Duration) {
+	v, _ := ctx.Get(ContextMiddlewareTimings)
+	timings, ok := v.(map[string]time.Duration)
+	if !ok {
+		timings = make(map[string]time.Duration)
+		ctx.Set(ContextMiddlewareTimings, timings)
+	}
+	timings[name] += d
+}
+
+// middlewareTimings returns the durations recorded with RecordMiddleware
+// as a JSON object of nanoseconds, e.g. {"auth":1200000}.
+func middlewareTimings(ctx *gin.Context) []byte {
+	v, _ := ctx.Get(ContextMiddlewareTimings)
+	timings, _ := v.(map[string]time.Duration)
+	obj := make(map[string]int64, len(timings))
+	for name, d := range timings {
+		obj[name] = int64(d)
+	}
+	b, _ := json.Marshal(obj)
+	return b
+}
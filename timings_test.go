@@ -0,0 +1,34 @@
+package glog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddlewareTimings(t *testing.T) {
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${middleware_timings}\n"})
+	r.Use(func(ctx *gin.Context) {
+		RecordMiddleware(ctx, "auth", 1200*time.Microsecond)
+		ctx.Next()
+	})
+	r.Use(func(ctx *gin.Context) {
+		RecordMiddleware(ctx, "ratelimit", 300*time.Microsecond)
+		RecordMiddleware(ctx, "ratelimit", 200*time.Microsecond)
+		ctx.Next()
+	})
+	r.GET("/", func(ctx *gin.Context) {})
+	serve(r, "GET", "/", nil)
+
+	bare, bareOut, _ := newTestRouter(t, LoggerConfig{Format: "${middleware_timings}\n"})
+	bare.GET("/", func(ctx *gin.Context) {})
+	serve(bare, "GET", "/", nil)
+
+	got := append(out.Lines(), bareOut.Lines()...)
+	want := []string{`{"auth":1200000,"ratelimit":500000}`, `{}`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+}
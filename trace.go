@@ -0,0 +1,63 @@
+package glog
+
+import (
+	"net/http"
+	"strings"
+)
+
+// traceIDs returns the trace and span IDs of r: from TraceContext when it
+// reports them, else from the W3C traceparent header, else from the B3
+// headers. Missing or malformed values are empty.
+func (config *LoggerConfig) traceIDs(r *http.Request) (traceID, spanID string) {
+	if config.TraceContext != nil {
+		if traceID, spanID = config.TraceContext(r); traceID != "" {
+			return traceID, spanID
+		}
+	}
+	if h := r.Header.Get("traceparent"); h != "" {
+		return parseTraceparent(h)
+	}
+	traceID, spanID = r.Header.Get("X-B3-TraceId"), r.Header.Get("X-B3-SpanId")
+	if !validTraceID(traceID, 16, 32) {
+		return "", ""
+	}
+	if !validTraceID(spanID, 16) {
+		spanID = ""
+	}
+	return traceID, spanID
+}
+
+// parseTraceparent parses a W3C traceparent header,
+// "<version>-<trace-id>-<parent-id>-<flags>".
+func parseTraceparent(h string) (traceID, spanID string) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || !isLowerHex(parts[0]) {
+		return "", ""
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", ""
+	}
+	if !validTraceID(parts[1], 32) || !validTraceID(parts[2], 16) || len(parts[3]) != 2 || !isLowerHex(parts[3]) {
+		return "", ""
+	}
+	return parts[1], parts[2]
+}
+
+// validTraceID reports whether id is lowercase hex of one of the lengths
+// and not all zeros.
+func validTraceID(id string, lengths ...int) bool {
+	ok := false
+	for _, n := range lengths {
+		ok = ok || len(id) == n
+	}
+	return ok && isLowerHex(id) && strings.Trim(id, "0") != ""
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
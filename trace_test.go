@@ -0,0 +1,69 @@
+package glog
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+func TestTraceIDs(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		header []string
+		want   string
+	}{
+		{"traceparent", []string{"traceparent", "00-" + testTraceID + "-" + testSpanID + "-01"}, testTraceID + " " + testSpanID},
+		{"future version", []string{"traceparent", "01-" + testTraceID + "-" + testSpanID + "-00-extra"}, testTraceID + " " + testSpanID},
+		{"uppercase", []string{"traceparent", "00-" + strings.ToUpper(testTraceID) + "-" + testSpanID + "-01"}, " "},
+		{"zero trace", []string{"traceparent", "00-" + strings.Repeat("0", 32) + "-" + testSpanID + "-01"}, " "},
+		{"short span", []string{"traceparent", "00-" + testTraceID + "-00f067-01"}, " "},
+		{"version ff", []string{"traceparent", "ff-" + testTraceID + "-" + testSpanID + "-01"}, " "},
+		{"extra fields in 00", []string{"traceparent", "00-" + testTraceID + "-" + testSpanID + "-01-x"}, " "},
+		{"b3", []string{"X-B3-TraceId", testTraceID, "X-B3-SpanId", testSpanID}, testTraceID + " " + testSpanID},
+		{"b3 64-bit", []string{"X-B3-TraceId", testSpanID, "X-B3-SpanId", testSpanID}, testSpanID + " " + testSpanID},
+		{"b3 bad span", []string{"X-B3-TraceId", testTraceID, "X-B3-SpanId", "xyz"}, testTraceID + " "},
+		{"b3 bad trace", []string{"X-B3-TraceId", "not-hex", "X-B3-SpanId", testSpanID}, " "},
+		{"none", nil, " "},
+	} {
+		r, out, _ := newTestRouter(t, LoggerConfig{Format: "${trace_id} ${span_id}\n"})
+		r.GET("/", func(ctx *gin.Context) {})
+		serve(r, "GET", "/", nil, tc.header...)
+		if got := strings.TrimSuffix(out.String(), "\n"); got != tc.want {
+			t.Errorf("%s: entry = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// traceKey holds a trace ID in the request context in the tests.
+type traceKey struct{}
+
+func TestTraceContextPreferred(t *testing.T) {
+	fromContext := func(r *http.Request) (string, string) {
+		if v, ok := r.Context().Value(traceKey{}).(string); ok {
+			return v, "1111111111111111"
+		}
+		return "", ""
+	}
+	r, out, _ := newTestRouter(t, LoggerConfig{Format: "${trace_id} ${span_id}\n", TraceContext: fromContext})
+	r.Use(func(ctx *gin.Context) {
+		if ctx.Query("span") != "" {
+			ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), traceKey{}, strings.Repeat("a", 32)))
+		}
+	})
+	r.GET("/", func(ctx *gin.Context) {})
+	header := []string{"traceparent", "00-" + testTraceID + "-" + testSpanID + "-01"}
+	serve(r, "GET", "/?span=1", nil, header...)
+	serve(r, "GET", "/", nil, header...)
+	want := strings.Repeat("a", 32) + " 1111111111111111\n" + testTraceID + " " + testSpanID + "\n"
+	if out.String() != want {
+		t.Errorf("entries = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,72 @@
+package glog
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextUpstreamLatency holds a time.Duration reported by the
+// upstream_latency tag, e.g. set by a proxy handler that measures its
+// upstream itself. It takes precedence over UpstreamTransport.
+const ContextUpstreamLatency = "context_upstream_latency"
+
+type (
+	// upstreamKey is the request context key of the upstreamTimer.
+	upstreamKey struct{}
+
+	// upstreamTimer accumulates the round trips of UpstreamTransport.
+	upstreamTimer struct {
+		d int64
+	}
+
+	upstreamTransport struct {
+		rt http.RoundTripper
+	}
+)
+
+// UpstreamTransport wraps rt, http.DefaultTransport when nil, to time the
+// round trips made with the context of a logged request, e.g. by a
+// httputil.ReverseProxy serving it. The upstream_latency tag reports the
+// total time until the response headers arrived.
+//
+//	proxy.Transport = glog.UpstreamTransport(nil)
+func UpstreamTransport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return upstreamTransport{rt: rt}
+}
+
+func (t upstreamTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.rt.RoundTrip(r)
+	if timer, ok := r.Context().Value(upstreamKey{}).(*upstreamTimer); ok {
+		atomic.AddInt64(&timer.d, int64(time.Since(start)))
+	}
+	return resp, err
+}
+
+// trackUpstream attaches a timer for UpstreamTransport to the request.
+func trackUpstream(ctx *gin.Context) *upstreamTimer {
+	timer := new(upstreamTimer)
+	ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), upstreamKey{}, timer))
+	return timer
+}
+
+// upstreamLatency returns the upstream latency of the request, -1 when
+// none was recorded.
+func upstreamLatency(ctx *gin.Context, timer *upstreamTimer) time.Duration {
+	if d, ok := ctx.Value(ContextUpstreamLatency).(time.Duration); ok {
+		return d
+	}
+	if timer != nil {
+		if d := atomic.LoadInt64(&timer.d); d > 0 {
+			return time.Duration(d)
+		}
+	}
+	return -1
+}
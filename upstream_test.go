@@ -0,0 +1,93 @@
+package glog
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReverseProxy(t *testing.T) {
+	const chunks, chunkSize = 64, 16 << 10
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("first\n"))
+		w.(http.Flusher).Flush()
+		// The rest only comes once the client saw the first line, which
+		// needs every layer to flush.
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+		chunk := strings.Repeat("x", chunkSize)
+		for i := 0; i < chunks; i++ {
+			w.Write([]byte(chunk))
+		}
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.FlushInterval = -1
+	proxy.Transport = UpstreamTransport(nil)
+	r, out, m := newTestRouter(t, LoggerConfig{
+		Format:             "${status} ${bytes_out} ${upstream_latency} ${response}\n",
+		MaxResponseLogSize: 1024,
+	})
+	r.Any("/*path", gin.WrapH(proxy))
+	front := httptest.NewServer(r)
+	defer front.Close()
+
+	resp, err := http.Get(front.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	br := bufio.NewReader(resp.Body)
+	done := make(chan string, 1)
+	go func() {
+		line, _ := br.ReadString('\n')
+		done <- line
+	}()
+	select {
+	case line := <-done:
+		if line != "first\n" {
+			t.Fatalf("first line = %q", line)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("first line not flushed through the proxy")
+	}
+	close(release)
+	n, _ := br.Discard(chunks * chunkSize)
+	if n != chunks*chunkSize {
+		t.Fatalf("client read %d bytes", n)
+	}
+	resp.Body.Close()
+	front.Close()
+	m.Flush()
+
+	fields := strings.SplitN(strings.TrimSuffix(out.String(), "\n"), " ", 4)
+	if len(fields) != 4 {
+		t.Fatalf("entry = %.200q", out)
+	}
+	total := len("first\n") + chunks*chunkSize
+	if fields[0] != "200" || fields[1] != strconv.Itoa(total) {
+		t.Errorf("status, bytes_out = %s, %s, want 200, %d", fields[0], fields[1], total)
+	}
+	if d, err := strconv.ParseInt(fields[2], 10, 64); err != nil || time.Duration(d) < 20*time.Millisecond {
+		t.Errorf("upstream_latency = %s, want at least 20ms", fields[2])
+	}
+	// Only the head of the response was kept.
+	if want := "...(truncated, " + strconv.Itoa(total) + " bytes total)"; len(fields[3]) > 1024+len(want) || !strings.HasSuffix(fields[3], want) {
+		t.Errorf("response has %d bytes, want 1024 and %q", len(fields[3]), want)
+	}
+}